@@ -0,0 +1,343 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/parquet"
+)
+
+const (
+	// RotateNone rotates files only by size
+	RotateNone = "none"
+	// RotateHourly starts a new file every hour
+	RotateHourly = "hourly"
+	// RotateDaily starts a new file every day
+	RotateDaily = "daily"
+)
+
+const (
+	// TimeLog rotates by the time of the logs, so each file contains the logs of an hour or day
+	TimeLog = "log"
+	// TimeIngestion rotates by the time at which the logs are written
+	TimeIngestion = "ingestion"
+)
+
+const (
+	// FormatNDJSON writes one JSON document per line
+	FormatNDJSON = "ndjson"
+	// FormatParquet writes Parquet files, which are only complete once closed
+	FormatParquet = "parquet"
+)
+
+// DefaultLayout is the default directory layout of the archive
+const DefaultLayout = "{app}/{year}/{month}/{day}"
+
+// FileDestination writes logs to an archive of NDJSON files, one log per line, or Parquet files.
+// Files are rotated by size and time, and compressed once closed.
+type FileDestination struct {
+	dir         string
+	layout      string
+	rotate      string
+	timeSource  string
+	maxSize     int64
+	format      string
+	compression string
+	parquet     parquet.Config
+	maxFiles    int
+	maxAge      time.Duration
+	mapper      integration.Mapper
+	verbose     bool
+	segments    map[segmentKey]*segment // open files
+	now         time.Time               // most recent time seen, to close the files of past periods
+	writes      int                     // number of calls to WriteLogs
+}
+
+// Config contains the parameters of the archive
+type Config struct {
+	// Dir is the root directory of the archive
+	Dir string
+	// Layout is the directory of the files, relative to Dir, with {app}, {year}, {month}, {day} and {hour}
+	// placeholders (default: DefaultLayout)
+	Layout string
+	// Rotate is RotateNone, RotateHourly or RotateDaily (default)
+	Rotate string
+	// TimeSource is the time used for rotation and the layout: TimeLog (default) or TimeIngestion
+	TimeSource string
+	// MaxSize is the size at which files are rotated, before compression (default: 100 MB)
+	MaxSize int64
+	// Format of the files: FormatNDJSON (default) or FormatParquet
+	Format string
+	// Compression of closed files: none, gzip (default) or zstd.
+	// Parquet files compress their column chunks instead: none, snappy (default), gzip or zstd.
+	Compression string
+	// RowGroupSize is the size of the row groups of Parquet files, before compression
+	// (default: parquet.DefaultRowGroupSize)
+	RowGroupSize int64
+	// MaxFiles is the maximum number of closed files kept, the oldest are deleted (default: no limit)
+	MaxFiles int
+	// MaxAge is the age at which closed files are deleted (default: no limit)
+	MaxAge time.Duration
+	// Mapper converts logs to the JSON documents written (default: integration.DefaultMapper).
+	// Parquet files have the columns of integration.Log instead.
+	Mapper  integration.Mapper
+	Verbose bool
+}
+
+var _ integration.BufferedLogWriter = &FileDestination{}
+
+// NewFileDestination creates a file archive with the given parameters.
+// Files left open by a previous run are compressed and retention is enforced on startup.
+// It is compulsory to call Close when done.
+func NewFileDestination(config Config) (*FileDestination, error) {
+	d := &FileDestination{
+		dir:         config.Dir,
+		layout:      config.Layout,
+		rotate:      config.Rotate,
+		timeSource:  config.TimeSource,
+		maxSize:     config.MaxSize,
+		format:      config.Format,
+		compression: config.Compression,
+		parquet:     parquet.Config{Compression: config.Compression, RowGroupSize: config.RowGroupSize},
+		maxFiles:    config.MaxFiles,
+		maxAge:      config.MaxAge,
+		mapper:      config.Mapper,
+		verbose:     config.Verbose,
+		segments:    make(map[segmentKey]*segment),
+	}
+	if d.dir == "" {
+		return nil, fmt.Errorf("a directory is needed")
+	}
+	if d.layout == "" {
+		d.layout = DefaultLayout
+	}
+	if filepath.IsAbs(d.layout) || strings.HasPrefix(filepath.Clean(d.layout), "..") {
+		return nil, fmt.Errorf("invalid layout %s, it must be relative to the archive directory", d.layout)
+	}
+	switch d.rotate {
+	case "":
+		d.rotate = RotateDaily
+	case RotateNone, RotateHourly, RotateDaily:
+	default:
+		return nil, fmt.Errorf("invalid rotation %s, expected %s, %s or %s", d.rotate, RotateNone, RotateHourly, RotateDaily)
+	}
+	switch d.timeSource {
+	case "":
+		d.timeSource = TimeLog
+	case TimeLog, TimeIngestion:
+	default:
+		return nil, fmt.Errorf("invalid time source %s, expected %s or %s", d.timeSource, TimeLog, TimeIngestion)
+	}
+	switch d.format {
+	case "":
+		d.format = FormatNDJSON
+	case FormatNDJSON, FormatParquet:
+	default:
+		return nil, fmt.Errorf("invalid format %s, expected %s or %s", d.format, FormatNDJSON, FormatParquet)
+	}
+	switch {
+	case d.format == FormatParquet:
+		if err := parquet.CheckConfig(d.parquet); err != nil {
+			return nil, err
+		}
+		d.compression = "none" // compressed by the Parquet writer
+	case d.compression == "":
+		d.compression = "gzip"
+	case d.compression != "none" && d.compression != "gzip" && d.compression != "zstd":
+		return nil, fmt.Errorf("invalid compression %s, expected none, gzip or zstd", d.compression)
+	}
+	if d.maxSize <= 0 {
+		d.maxSize = 100 << 20
+	}
+	if d.mapper == nil {
+		d.mapper = integration.DefaultMapper
+	}
+	if err := d.recover(); err != nil {
+		return nil, err
+	}
+	if err := d.enforceRetention(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WriteLogs appends the logs to the archive, and only returns once they are synced to disk.
+// Logs written to Parquet files are buffered until the files are closed.
+func (d *FileDestination) WriteLogs(_ context.Context, logs []integration.Log) error {
+	d.writes++
+	ingestionTime := time.Now().UTC()
+	written := make(map[*segment]bool)
+	for i := range logs {
+		l := &logs[i]
+		t := ingestionTime
+		if d.timeSource == TimeLog {
+			t = l.Time.UTC()
+		}
+		if t.After(d.now) {
+			d.now = t
+		}
+		var line []byte
+		if d.format == FormatNDJSON {
+			var err error
+			if line, err = json.Marshal(d.mapper(*l)); err != nil {
+				panic(err) // programming error
+			}
+		}
+		key := d.segmentKey(l.App, t)
+		s, ok := d.segments[key]
+		if ok && s.size > 0 && s.size+int64(len(line))+1 > d.maxSize {
+			delete(written, s) // synced when closed
+			if err := d.closeSegment(key); err != nil {
+				return err
+			}
+			ok = false
+		}
+		if !ok {
+			var err error
+			if s, err = openSegment(d.dir, key, d.format, d.parquet); err != nil {
+				return err
+			}
+			s.firstWrite = d.writes
+			d.segments[key] = s
+			if d.verbose {
+				log.Println("Archiving to", s.path)
+			}
+		}
+		if s.parquet != nil {
+			if err := s.writeParquet(l); err != nil {
+				return err
+			}
+		} else if err := s.write(line); err != nil {
+			return err
+		}
+		written[s] = true
+	}
+	for s := range written {
+		if s.parquet != nil {
+			continue // synced once complete
+		}
+		if err := s.sync(); err != nil {
+			return err
+		}
+	}
+	// close the files of past periods (without time rotation, the files no longer written to)
+	if d.timeSource == TimeIngestion && ingestionTime.After(d.now) {
+		d.now = ingestionTime
+	}
+	for key, s := range d.segments {
+		if (d.rotate == RotateNone && written[s]) || (d.rotate != RotateNone && d.periodEnd(key.period).After(d.now)) {
+			continue
+		}
+		if err := d.closeSegment(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Buffered returns how many of the last calls to WriteLogs wrote logs to Parquet files that are not closed yet
+func (d *FileDestination) Buffered() int {
+	first := 0
+	for _, s := range d.segments {
+		if s.parquet != nil && (first == 0 || s.firstWrite < first) {
+			first = s.firstWrite
+		}
+	}
+	if first == 0 {
+		return 0
+	}
+	return d.writes - first + 1
+}
+
+// Flush closes the Parquet files, so that the logs buffered in them are durable
+func (d *FileDestination) Flush(_ context.Context) error {
+	for key, s := range d.segments {
+		if s.parquet == nil {
+			continue
+		}
+		if err := d.closeSegment(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes and compresses the open files
+func (d *FileDestination) Close(_ context.Context) error {
+	var err error
+	for key := range d.segments {
+		if closeErr := d.closeSegment(key); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// segmentKey identifies the file logs are written to
+type segmentKey struct {
+	dir    string    // relative to the archive directory
+	period time.Time // start of the rotation period
+	stamp  string    // period in file names
+}
+
+// segmentKey returns the file for a log of the given app and time
+func (d *FileDestination) segmentKey(app int64, t time.Time) segmentKey {
+	var period time.Time
+	var stamp string
+	switch d.rotate {
+	case RotateHourly:
+		period = t.Truncate(time.Hour)
+		stamp = period.Format("2006-01-02T15")
+	case RotateDaily:
+		period = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		stamp = period.Format("2006-01-02")
+	}
+	dir := strings.NewReplacer(
+		"{app}", strconv.FormatInt(app, 10),
+		"{year}", t.Format("2006"),
+		"{month}", t.Format("01"),
+		"{day}", t.Format("02"),
+		"{hour}", t.Format("15"),
+	).Replace(d.layout)
+	return segmentKey{dir: filepath.Clean(dir), period: period, stamp: stamp}
+}
+
+// periodEnd returns the end of the rotation period starting at period
+func (d *FileDestination) periodEnd(period time.Time) time.Time {
+	if d.rotate == RotateHourly {
+		return period.Add(time.Hour)
+	}
+	return period.AddDate(0, 0, 1)
+}
+
+// closeSegment closes a file, compresses it and enforces the retention.
+// If a Parquet file can't be completed, all the open Parquet files are discarded, so that their logs are
+// written again.
+func (d *FileDestination) closeSegment(key segmentKey) error {
+	s := d.segments[key]
+	delete(d.segments, key)
+	if err := s.close(); err != nil {
+		if s.parquet == nil {
+			return err
+		}
+		s.discard()
+		for key, s := range d.segments {
+			if s.parquet != nil {
+				delete(d.segments, key)
+				s.discard()
+			}
+		}
+		return fmt.Errorf("%w: completing %s: %s", integration.ErrBufferDiscarded, s.path, err)
+	}
+	if err := compress(s.path, d.compression); err != nil {
+		return err
+	}
+	return d.enforceRetention()
+}
@@ -0,0 +1,248 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// files returns the paths of the files in dir, relative to it
+func files(t *testing.T, dir string) []string {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// textMapper returns the text of the logs as documents
+func textMapper(l integration.Log) interface{} { return l.Text }
+
+func at(hour, minute int) time.Time {
+	return time.Date(2021, 6, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewFileDestination(Config{Dir: dir, Compression: "none", MaxSize: 10, Mapper: textMapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// each line is 6 bytes long: the second line doesn't fit
+	logs := []integration.Log{{App: 1, Time: at(10, 0), Text: "one"}, {App: 1, Time: at(10, 1), Text: "two"}}
+	if err := d.WriteLogs(ctx, logs); err != nil {
+		t.Fatal(err)
+	}
+	// the open file is synced, but not renamed
+	want := []string{"1/2021/06/01/bugfender-2021-06-01-0001.ndjson", "1/2021/06/01/bugfender-2021-06-01-0002.ndjson"}
+	if got := files(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for i, text := range []string{`"one"`, `"two"`} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, want[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != text+"\n" {
+			t.Errorf("%s = %q, want %q", want[i], b, text+"\n")
+		}
+	}
+	if d.Buffered() != 0 {
+		t.Errorf("%d writes buffered, want 0", d.Buffered())
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateByTime(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewFileDestination(Config{Dir: dir, Rotate: RotateHourly, Layout: "{app}", Mapper: textMapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := d.WriteLogs(ctx, []integration.Log{{App: 1, Time: at(10, 59), Text: "ten"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := files(t, dir), []string{"1/bugfender-2021-06-01T10-0001.ndjson"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// a log of the next hour closes the file of the previous one, which is compressed
+	if err := d.WriteLogs(ctx, []integration.Log{{App: 1, Time: at(11, 0), Text: "eleven"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1/bugfender-2021-06-01T10-0001.ndjson.gz", "1/bugfender-2021-06-01T11-0001.ndjson"}
+	if got := files(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	f, err := os.Open(filepath.Join(dir, want[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "\"ten\"\n" {
+		t.Errorf("got %q, %v, want the log of 10:59", b, err)
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"1/bugfender-2021-06-01T10-0001.ndjson.gz", "1/bugfender-2021-06-01T11-0001.ndjson.gz"}
+	if got := files(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after closing, want %v", got, want)
+	}
+}
+
+func TestParquetRename(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewFileDestination(Config{Dir: dir, Format: FormatParquet, Layout: "{app}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := d.WriteLogs(ctx, []integration.Log{{App: 1, Time: at(10, i), Text: "log"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// incomplete until closed
+	if got, want := files(t, dir), []string{"1/bugfender-2021-06-01-0001.parquet.tmp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if d.Buffered() != 2 {
+		t.Errorf("%d writes buffered, want 2", d.Buffered())
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := files(t, dir), []string{"1/bugfender-2021-06-01-0001.parquet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if d.Buffered() != 0 {
+		t.Errorf("%d writes buffered after flushing, want 0", d.Buffered())
+	}
+}
+
+func TestParquetDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewFileDestination(Config{Dir: dir, Format: FormatParquet, Layout: "{app}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	logs := []integration.Log{{App: 1, Time: at(10, 0)}, {App: 2, Time: at(10, 0)}}
+	if err := d.WriteLogs(ctx, logs); err != nil {
+		t.Fatal(err)
+	}
+	// a file that can't be completed discards all the open files
+	for _, s := range d.segments {
+		if strings.HasPrefix(s.path, filepath.Join(dir, "1")) {
+			_ = s.file.Close()
+		}
+	}
+	if err := d.Flush(ctx); !errors.Is(err, integration.ErrBufferDiscarded) {
+		t.Fatalf("got error %v, want ErrBufferDiscarded", err)
+	}
+	if got := files(t, dir); len(got) != 0 {
+		t.Errorf("got %v, want the files removed", got)
+	}
+	if d.Buffered() != 0 {
+		t.Errorf("%d writes buffered, want 0", d.Buffered())
+	}
+	// the logs written again are archived
+	if err := d.WriteLogs(ctx, logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1/bugfender-2021-06-01-0001.parquet", "2/bugfender-2021-06-01-0001.parquet"}
+	if got := files(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	// closed files, the newest first
+	closed := []string{
+		"1/bugfender-2021-06-05-0001.ndjson.gz",
+		"1/bugfender-2021-06-04-0001.ndjson.gz",
+		"2/bugfender-2021-06-03-0001.ndjson.gz",
+		"3/bugfender-2021-06-02-0001.ndjson.gz",
+	}
+	for i, name := range closed {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-time.Duration(i) * 24 * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files left open by a previous run are compressed, and incomplete files removed
+	for _, name := range []string{"1/bugfender-2021-06-06-0001.ndjson", "1/bugfender-2021-06-06-0001.parquet.tmp"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the oldest files beyond the maximum number are deleted, with their empty directories
+	d, err := NewFileDestination(Config{Dir: dir, Layout: "{app}", MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1/bugfender-2021-06-04-0001.ndjson.gz", "1/bugfender-2021-06-05-0001.ndjson.gz",
+		"1/bugfender-2021-06-06-0001.ndjson.gz"}
+	if got := files(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "3")); !os.IsNotExist(err) {
+		t.Errorf("empty directory not removed: %v", err)
+	}
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// and so are the files older than the maximum age
+	d, err = NewFileDestination(Config{Dir: dir, Layout: "{app}", MaxAge: 12 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := files(t, dir); !reflect.DeepEqual(got, []string{want[1], want[2]}) {
+		t.Errorf("got %v, want %v", got, []string{want[1], want[2]})
+	}
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
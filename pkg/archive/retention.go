@@ -0,0 +1,91 @@
+package archive
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveFile is a closed file of the archive
+type archiveFile struct {
+	path    string
+	modTime time.Time
+}
+
+// walk calls f for each file of the archive that is not open
+func (d *FileDestination) walk(f func(path string, info os.FileInfo) error) error {
+	open := make(map[string]bool, len(d.segments))
+	for _, s := range d.segments {
+		open[s.path] = true
+	}
+	return filepath.Walk(d.dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == d.dir {
+			return nil // nothing archived yet
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || open[path] || !strings.HasPrefix(info.Name(), filePrefix) {
+			return nil
+		}
+		return f(path, info)
+	})
+}
+
+// recover compresses the files left open by a previous run, and removes temporary files
+func (d *FileDestination) recover() error {
+	return d.walk(func(path string, info os.FileInfo) error {
+		switch {
+		case strings.HasSuffix(path, tmpSuffix):
+			return os.Remove(path)
+		case strings.HasSuffix(path, fileSuffix):
+			return compress(path, d.compression)
+		}
+		return nil
+	})
+}
+
+// enforceRetention deletes the oldest closed files beyond the maximum number of files or age
+func (d *FileDestination) enforceRetention() error {
+	if d.maxFiles <= 0 && d.maxAge <= 0 {
+		return nil
+	}
+	var files []archiveFile
+	err := d.walk(func(path string, info os.FileInfo) error {
+		if !strings.HasSuffix(path, tmpSuffix) {
+			files = append(files, archiveFile{path: path, modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) }) // newest first
+	for i, f := range files {
+		if (d.maxFiles <= 0 || i < d.maxFiles) && (d.maxAge <= 0 || time.Since(f.modTime) < d.maxAge) {
+			continue
+		}
+		if d.verbose {
+			log.Println("Deleting", f.path)
+		}
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+		d.removeEmptyDirs(filepath.Dir(f.path))
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents, up to the archive directory, while they are empty
+func (d *FileDestination) removeEmptyDirs(dir string) {
+	root := filepath.Clean(d.dir)
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil { // not empty
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
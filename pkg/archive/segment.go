@@ -0,0 +1,218 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/parquet"
+)
+
+const (
+	filePrefix = "bugfender-"
+	fileSuffix = ".ndjson"
+	tmpSuffix  = ".tmp"
+)
+
+// segment is an open file of the archive
+type segment struct {
+	path       string // Parquet files are written to path+tmpSuffix until they're closed
+	file       *os.File
+	w          *bufio.Writer
+	parquet    *parquet.Writer // nil for NDJSON files
+	size       int64
+	firstWrite int // call to WriteLogs that opened the file
+}
+
+// openSegment creates a new file for the segment, numbered after the existing ones of the same period.
+// Parquet files are written with the given parameters.
+func openSegment(root string, key segmentKey, format string, parquetConfig parquet.Config) (*segment, error) {
+	dir := filepath.Join(root, key.dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	stamp := key.stamp
+	if stamp == "" { // no time rotation
+		stamp = time.Now().UTC().Format("2006-01-02T15-04-05")
+	}
+	prefix := filePrefix + stamp + "-"
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	seq := 0
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.SplitN(name[len(prefix):], ".", 2)[0])
+		if err == nil && n > seq {
+			seq = n
+		}
+	}
+	suffix := fileSuffix
+	if format == FormatParquet {
+		suffix = parquet.Extension
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s%04d%s", prefix, seq+1, suffix))
+	openPath := path
+	if format == FormatParquet {
+		openPath += tmpSuffix // incomplete until closed
+	}
+	// #nosec G304 the path is in the archive directory
+	file, err := os.OpenFile(openPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncDir(dir); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	s := &segment{path: path, file: file, w: bufio.NewWriter(file)}
+	if format == FormatParquet {
+		if s.parquet, err = parquet.NewWriter(s.w, parquetConfig); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *segment) write(line []byte) error {
+	if _, err := s.w.Write(line); err != nil {
+		return err
+	}
+	if err := s.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	s.size += int64(len(line)) + 1
+	return nil
+}
+
+// writeParquet adds a log to a Parquet file
+func (s *segment) writeParquet(l *integration.Log) error {
+	if err := s.parquet.Write(l); err != nil {
+		return err
+	}
+	s.size = s.parquet.Size()
+	return nil
+}
+
+// sync flushes the written lines to disk
+func (s *segment) sync() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// close closes the file. Parquet files are completed, and then renamed to their final path.
+func (s *segment) close() error {
+	if s.parquet != nil {
+		if err := s.parquet.Close(); err != nil {
+			_ = s.file.Close()
+			return err
+		}
+	}
+	if err := s.sync(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.parquet == nil {
+		return nil
+	}
+	if err := os.Rename(s.file.Name(), s.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(s.path))
+}
+
+// discard closes an incomplete Parquet file and removes it
+func (s *segment) discard() {
+	_ = s.file.Close()
+	if err := os.Remove(s.path + tmpSuffix); err != nil && !os.IsNotExist(err) {
+		log.Printf("ERROR: removing %s: %s", s.path+tmpSuffix, err)
+	}
+}
+
+// compress replaces a closed file with its compressed version, if compression is not none
+func compress(path, compression string) error {
+	var ext string
+	switch compression {
+	case "gzip":
+		ext = ".gz"
+	case "zstd":
+		ext = ".zst"
+	default:
+		return nil
+	}
+	// #nosec G304 the path is in the archive directory
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	tmp := path + ext + tmpSuffix
+	// #nosec G304 the path is in the archive directory
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	var w io.WriteCloser
+	if compression == "gzip" {
+		w = gzip.NewWriter(dst)
+	} else if w, err = zstd.NewWriter(dst); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	_, err = io.Copy(w, src)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+ext)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("compressing %s: %s", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir syncs a directory, so that the files created or renamed in it survive a crash
+func syncDir(dir string) error {
+	// #nosec G304 the path is in the archive directory
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -7,6 +7,7 @@ import (
 
 // ExponentialBackoff implements an exponential backoff waiting time
 type ExponentialBackoff struct {
+	initialWaitTime time.Duration
 	currentWaitTime time.Duration
 	maxWaitTime     time.Duration
 }
@@ -14,6 +15,7 @@ type ExponentialBackoff struct {
 // NewExponential creates a new ExponentialBackoff
 func NewExponential(initialWaitTime, maxWaitTime time.Duration) ExponentialBackoff {
 	return ExponentialBackoff{
+		initialWaitTime: initialWaitTime,
 		currentWaitTime: initialWaitTime,
 		maxWaitTime:     maxWaitTime,
 	}
@@ -22,8 +24,17 @@ func NewExponential(initialWaitTime, maxWaitTime time.Duration) ExponentialBacko
 // Wait wait the time that's needed.
 // Returns quickly if the context is cancelled
 func (b *ExponentialBackoff) Wait(ctx context.Context) {
+	b.WaitOrWake(ctx, nil)
+}
+
+// WaitOrWake waits like Wait, but also returns quickly when something is received from wake.
+// Waking up resets the waiting time to the initial one.
+func (b *ExponentialBackoff) WaitOrWake(ctx context.Context, wake <-chan struct{}) {
 	select {
 	case <-ctx.Done(): // wait no longer if context is cancelled
+	case <-wake:
+		b.currentWaitTime = b.initialWaitTime
+		return
 	case <-time.After(b.currentWaitTime):
 	}
 	// backoff
@@ -0,0 +1,43 @@
+package backoff
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Attempt makes an attempt of a retryable operation, and returns the error and whether it's retryable,
+// with the time to wait before retrying if the other end says so (0 to back off exponentially)
+type Attempt func() (retryAfter time.Duration, retryable bool, err error)
+
+// Retry calls attempt until it succeeds, it returns an error that is not retryable, or it was retried retries times.
+// Between attempts it waits the time attempt returns, or backs off exponentially from initialWaitTime to maxWaitTime.
+// Returns quickly if the context is cancelled.
+func Retry(ctx context.Context, retries int, initialWaitTime, maxWaitTime time.Duration, attempt Attempt) error {
+	b := NewExponential(initialWaitTime, maxWaitTime)
+	for n := 0; ; n++ {
+		retryAfter, retryable, err := attempt()
+		if err == nil || !retryable {
+			return err
+		}
+		if n == retries || ctx.Err() != nil {
+			return fmt.Errorf("%s (after %d retries)", err, n)
+		}
+		log.Printf("Retrying: %s", err)
+		b.WaitAtLeast(ctx, retryAfter)
+	}
+}
+
+// WaitAtLeast waits the given time if it's not 0, for instance when a server says when to retry, or like Wait
+// otherwise. Returns quickly if the context is cancelled.
+func (b *ExponentialBackoff) WaitAtLeast(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		b.Wait(ctx)
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
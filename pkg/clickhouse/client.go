@@ -0,0 +1,211 @@
+package clickhouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// DefaultTable is the default table of the logs
+const DefaultTable = "bugfender_logs"
+
+// maxBatchBytes is the maximum size of the rows inserted in one request, before compression
+const maxBatchBytes = 16 << 20
+
+// Client inserts logs into a ClickHouse table, through the HTTP interface
+type Client struct {
+	url        string
+	database   string
+	table      string
+	username   string
+	password   string
+	settings   url.Values // settings of the inserts
+	batchSize  int
+	gzip       bool
+	httpClient *http.Client
+}
+
+// Config contains the parameters to connect to ClickHouse
+type Config struct {
+	// URL is the base URL of the HTTP interface, eg. http://localhost:8123
+	URL string
+	// Database of the table (default: the default database of the user)
+	Database string
+	// Table of the logs, created if it doesn't exist (default: DefaultTable)
+	Table    string
+	Username string
+	Password string
+	// AsyncInsert lets ClickHouse buffer the inserts, to insert fewer and bigger parts when there are few logs
+	// per request. WriteLogs still waits until the logs are inserted.
+	AsyncInsert bool
+	// AsyncInsertBusyTimeout is the maximum time ClickHouse buffers async inserts (default: ClickHouse's, 200ms)
+	AsyncInsertBusyTimeout time.Duration
+	// BatchSize is the maximum number of logs inserted in one request (default: 10000)
+	BatchSize int
+	// Gzip compresses the requests
+	Gzip bool
+	// Timeout of each request (default: 60s)
+	Timeout time.Duration
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates a ClickHouse client, and creates the table or adds the columns missing
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		url:        strings.TrimSuffix(config.URL, "/") + "/",
+		database:   config.Database,
+		table:      config.Table,
+		username:   config.Username,
+		password:   config.Password,
+		settings:   url.Values{},
+		batchSize:  config.BatchSize,
+		gzip:       config.Gzip,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("a ClickHouse URL is needed")
+	}
+	if c.table == "" {
+		c.table = DefaultTable
+	}
+	if config.AsyncInsert {
+		c.settings.Set("async_insert", "1")
+		c.settings.Set("wait_for_async_insert", "1") // the state must not advance before the logs are inserted
+		if config.AsyncInsertBusyTimeout > 0 {
+			c.settings.Set("async_insert_busy_timeout_ms",
+				strconv.FormatInt(int64(config.AsyncInsertBusyTimeout/time.Millisecond), 10))
+		}
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 10000
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = 60 * time.Second
+	}
+	if err := c.migrate(context.Background()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// migrate creates the table, and adds the columns missing to the table created by previous versions.
+// The logs with the same uuid have the same sorting key, so the ReplacingMergeTree engine removes the duplicates
+// when merging parts.
+func (c *Client) migrate(ctx context.Context) error {
+	table := c.qualifiedTable()
+	definitions := make([]string, len(columns))
+	additions := make([]string, len(columns))
+	for i, col := range columns {
+		definitions[i] = quote(col.Name) + " " + col.Type
+		additions[i] = "ADD COLUMN IF NOT EXISTS " + definitions[i]
+	}
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (\n\t" + strings.Join(definitions, ",\n\t") + "\n)" +
+			"\nENGINE = ReplacingMergeTree" +
+			"\nPARTITION BY toYYYYMM(time)" +
+			"\nORDER BY (app, device_udid, time, absolute_time, uuid)",
+		"ALTER TABLE " + table + "\n\t" + strings.Join(additions, ",\n\t"),
+	}
+	for _, statement := range statements {
+		if err := c.post(ctx, url.Values{}, []byte(statement)); err != nil {
+			return fmt.Errorf("migrating table %s: %s", table, err)
+		}
+	}
+	return nil
+}
+
+// WriteLogs inserts logs into ClickHouse, in batches in the JSONEachRow format.
+// Logs inserted again (eg. after a failure) are removed by ClickHouse when merging parts, so queries that
+// must not count them twice need the FINAL modifier.
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	var body bytes.Buffer
+	count := 0
+	for i := range logs {
+		line := row(&logs[i])
+		if count == c.batchSize || (count > 0 && body.Len()+len(line) > maxBatchBytes) {
+			if err := c.insert(ctx, body.Bytes()); err != nil {
+				return err
+			}
+			body.Reset()
+			count = 0
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+		count++
+	}
+	if count > 0 {
+		return c.insert(ctx, body.Bytes())
+	}
+	return nil
+}
+
+// insert inserts a batch of rows
+func (c *Client) insert(ctx context.Context, rows []byte) error {
+	params := url.Values{}
+	for k, v := range c.settings {
+		params[k] = v
+	}
+	params.Set("query", "INSERT INTO "+c.qualifiedTable()+" FORMAT JSONEachRow")
+	return c.post(ctx, params, rows)
+}
+
+// post sends a query to ClickHouse, in the query parameter or the body
+func (c *Client) post(ctx context.Context, params url.Values, body []byte) error {
+	var contentEncoding string
+	if c.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+		contentEncoding = "gzip"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"?"+params.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending to ClickHouse: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("sending to ClickHouse: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = ioutil.ReadAll(res.Body) // so the connection can be reused
+	return nil
+}
+
+// qualifiedTable returns the quoted name of the table, qualified with the database if any
+func (c *Client) qualifiedTable() string {
+	if c.database == "" {
+		return quote(c.table)
+	}
+	return quote(c.database) + "." + quote(c.table)
+}
+
+// quote quotes an identifier
+func quote(name string) string {
+	return "`" + strings.Replace(strings.Replace(name, `\`, `\\`, -1), "`", "\\`", -1) + "`"
+}
@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// timeFormat is the format of the times sent, understood by DateTime64 columns with the default input format
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+// columns of the logs table
+var columns = integration.Columns(columnType)
+
+// columnType returns the ClickHouse type of a column, Nullable if optional, and the conversion of its values to JSON
+func columnType(name string, kind integration.ColumnKind, nullable bool) (string, func(interface{}) interface{}) {
+	var typ string
+	var convert func(interface{}) interface{}
+	switch kind {
+	case integration.ColumnUUID:
+		typ = "UUID"
+		convert = func(v interface{}) interface{} { return v.(uuid.UUID).String() }
+	case integration.ColumnTime:
+		typ = "DateTime64(6, 'UTC')"
+		convert = func(v interface{}) interface{} { return v.(time.Time).UTC().Format(timeFormat) }
+	case integration.ColumnString:
+		typ = "String"
+	case integration.ColumnInt64:
+		typ = "Int64"
+	case integration.ColumnInt:
+		typ = "Int32"
+	case integration.ColumnUint64:
+		typ = "UInt64"
+	case integration.ColumnSeverity:
+		typ = "UInt8"
+	case integration.ColumnJSON:
+		typ = "String"
+		convert = func(v interface{}) interface{} { return string(v.([]byte)) }
+	default:
+		panic(fmt.Sprintf("column %s: kind %d not supported in ClickHouse", name, kind)) // programming error
+	}
+	if nullable {
+		typ = "Nullable(" + typ + ")"
+	}
+	if name == "log_level_name" { // few distinct values
+		typ = "LowCardinality(" + typ + ")"
+	}
+	return typ, convert
+}
+
+// row returns the log as a JSONEachRow row
+func row(l *integration.Log) []byte {
+	values := make(map[string]interface{}, len(columns))
+	for _, c := range columns {
+		values[c.Name] = c.Value(l)
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		panic(err) // programming error
+	}
+	return b
+}
@@ -0,0 +1,112 @@
+package devicestate
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Value is the value of a device key at a given time
+type Value struct {
+	// Value is nil if the key was removed
+	Value *string   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// Update contains the latest key-value pairs of a device found in a page of logs.
+// Writers combine it with the state already stored, keeping the most recent value of each key.
+type Update struct {
+	App        int64
+	DeviceUDID string
+	// DeviceName, DeviceType, VersionVersion and OSVersion are the values in the most recent log
+	DeviceName     string
+	DeviceType     string
+	VersionVersion string
+	OSVersion      string
+	// Updated is the time of the most recent log
+	Updated time.Time
+	// Keys are the latest values by key. Dots in keys are replaced with underscores.
+	Keys map[string]Value
+}
+
+// ID returns the ID of the device state document
+func (u *Update) ID() string {
+	return strconv.FormatInt(u.App, 10) + "-" + u.DeviceUDID
+}
+
+// Tracker maintains an index with the latest key-value pairs of each device
+type Tracker struct {
+	writer integration.DocumentWriter
+}
+
+var _ integration.LogProcessor = &Tracker{}
+
+// NewTracker creates a Tracker that writes the device states to writer, which must support scripted documents
+// (see Update.Document)
+func NewTracker(writer integration.DocumentWriter) *Tracker {
+	return &Tracker{writer: writer}
+}
+
+// ProcessLogs writes the key-value pairs in the logs, which are not modified
+func (t *Tracker) ProcessLogs(ctx context.Context, logs []integration.Log) ([]integration.Log, error) {
+	updates := Collect(logs)
+	if len(updates) == 0 {
+		return logs, nil
+	}
+	docs := make([]integration.Document, len(updates))
+	for i := range updates {
+		docs[i] = updates[i].Document()
+	}
+	return logs, t.writer.WriteDocuments(ctx, docs)
+}
+
+// Collect returns one Update for each device with key-value logs
+func Collect(logs []integration.Log) []Update {
+	type device struct {
+		app  int64
+		udid string
+	}
+	updates := make(map[device]*Update)
+	var devices []device
+	for i := range logs {
+		l := &logs[i]
+		if l.KeyValueKey == nil || *l.KeyValueKey == "" {
+			continue
+		}
+		d := device{l.App, l.DeviceUDID}
+		u, ok := updates[d]
+		if !ok {
+			u = &Update{
+				App:        l.App,
+				DeviceUDID: l.DeviceUDID,
+				Keys:       make(map[string]Value),
+			}
+			updates[d] = u
+			devices = append(devices, d)
+		}
+		if !l.Time.Before(u.Updated) {
+			u.Updated = l.Time
+			u.DeviceName, u.DeviceType = l.DeviceName, l.DeviceType
+			u.VersionVersion, u.OSVersion = l.VersionVersion, l.OSVersion
+		}
+		key := strings.Replace(*l.KeyValueKey, ".", "_", -1)
+		if current, ok := u.Keys[key]; !ok || !l.Time.Before(current.Time) {
+			u.Keys[key] = Value{Value: l.KeyValueValue, Time: l.Time}
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].app != devices[j].app {
+			return devices[i].app < devices[j].app
+		}
+		return devices[i].udid < devices[j].udid
+	})
+	result := make([]Update, 0, len(devices))
+	for _, d := range devices {
+		result = append(result, *updates[d])
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package devicestate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func str(s string) *string { return &s }
+
+func keyValueLog(device string, t time.Time, key string, value *string) integration.Log {
+	return integration.Log{
+		App:           1,
+		DeviceUDID:    device,
+		DeviceName:    "name at " + t.Format(time.Kitchen),
+		Time:          t,
+		KeyValueKey:   str(key),
+		KeyValueValue: value,
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	logs := []integration.Log{
+		keyValueLog("d2", t0, "tier", str("free")),
+		keyValueLog("d1", t0.Add(2*time.Minute), "tier", str("premium")),
+		keyValueLog("d1", t0, "tier", str("free")), // older, received later
+		keyValueLog("d1", t0.Add(time.Minute), "user.email", str("a@example.com")),
+		keyValueLog("d1", t0.Add(3*time.Minute), "user.email", nil), // removed
+		{App: 1, DeviceUDID: "d1", Time: t0.Add(time.Hour), Text: "not a key-value log"},
+		keyValueLog("d3", t0, "", str("no key")),
+	}
+	updates := Collect(logs)
+	if len(updates) != 2 || updates[0].DeviceUDID != "d1" || updates[1].DeviceUDID != "d2" {
+		t.Fatalf("got updates %+v, want devices d1 and d2", updates)
+	}
+	d1 := updates[0]
+	if d1.ID() != "1-d1" {
+		t.Errorf("ID %s, want 1-d1", d1.ID())
+	}
+	if !d1.Updated.Equal(t0.Add(3*time.Minute)) || d1.DeviceName != "name at 10:03AM" {
+		t.Errorf("updated %s with name %s, want the ones of the latest key-value log", d1.Updated, d1.DeviceName)
+	}
+	want := map[string]Value{
+		"tier":       {Value: str("premium"), Time: t0.Add(2 * time.Minute)},
+		"user_email": {Value: nil, Time: t0.Add(3 * time.Minute)},
+	}
+	if !reflect.DeepEqual(d1.Keys, want) {
+		t.Errorf("keys %v, want %v", d1.Keys, want)
+	}
+}
+
+func TestCollectTies(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	// with the same time, the later log in the page wins, and keys that only differ in dots and underscores are the same
+	updates := Collect([]integration.Log{
+		keyValueLog("d1", t0, "a.b", str("first")),
+		keyValueLog("d1", t0, "a_b", str("second")),
+	})
+	if len(updates) != 1 {
+		t.Fatalf("got updates %+v, want one", updates)
+	}
+	if v := updates[0].Keys["a_b"]; len(updates[0].Keys) != 1 || v.Value == nil || *v.Value != "second" {
+		t.Errorf("keys %v, want a_b=second", updates[0].Keys)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	u := Collect([]integration.Log{keyValueLog("d1", t0, "tier", str("free"))})[0]
+	doc := u.Document()
+	if doc.ID != "1-d1" || doc.Script != upsertScript {
+		t.Fatalf("got document %s with script %q", doc.ID, doc.Script)
+	}
+	params := doc.Body.(map[string]interface{})
+	if params["updated"] != "2023-01-01T09:00:00.000Z" {
+		t.Errorf("updated %v, want it in UTC, in the script time format", params["updated"])
+	}
+	keys := params["keys"].(map[string]interface{})
+	want := map[string]interface{}{"tier": map[string]interface{}{"value": str("free"), "time": "2023-01-01T09:00:00.000Z"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,54 @@
+package devicestate
+
+import (
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// upsertScript combines a device state update with the existing device state document
+const upsertScript = `
+def s = ctx._source;
+s['device.udid'] = params.udid;
+s.app = params.app;
+if (s.updated == null || params.updated.compareTo(s.updated) >= 0) {
+	s.updated = params.updated;
+	s['device.name'] = params.device_name;
+	s['device.type'] = params.device_type;
+	s['version.version'] = params.version;
+	s.os_version = params.os_version;
+}
+if (s.keys == null) {
+	s.keys = [:];
+}
+for (entry in params.keys.entrySet()) {
+	def current = s.keys[entry.getKey()];
+	if (current == null || entry.getValue().time.compareTo(current.time) >= 0) {
+		s.keys[entry.getKey()] = entry.getValue();
+	}
+}
+`
+
+// Document returns the document that combines the update with the existing device state document, with a script.
+// The most recent value of each key is kept, even if updates are received out of order.
+func (u *Update) Document() integration.Document {
+	keys := make(map[string]interface{}, len(u.Keys))
+	for k, v := range u.Keys {
+		keys[k] = map[string]interface{}{
+			"value": v.Value,
+			"time":  v.Time.UTC().Format(integration.ScriptTimeFormat),
+		}
+	}
+	return integration.Document{
+		ID: u.ID(),
+		Body: map[string]interface{}{
+			"udid":        u.DeviceUDID,
+			"app":         u.App,
+			"updated":     u.Updated.UTC().Format(integration.ScriptTimeFormat),
+			"device_name": u.DeviceName,
+			"device_type": u.DeviceType,
+			"version":     u.VersionVersion,
+			"os_version":  u.OSVersion,
+			"keys":        keys,
+		},
+		Script: upsertScript,
+	}
+}
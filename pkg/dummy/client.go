@@ -9,16 +9,21 @@ import (
 
 // ConsoleDestination prints logs to console
 type ConsoleDestination struct {
+	mapper integration.Mapper
 }
 
 var _ integration.LogWriter = ConsoleDestination{}
 
-func NewConsoleDestination() ConsoleDestination {
-	return ConsoleDestination{}
+// NewConsoleDestination creates a ConsoleDestination, which prints the documents returned by mapper
+func NewConsoleDestination(mapper integration.Mapper) ConsoleDestination {
+	if mapper == nil {
+		mapper = integration.DefaultMapper
+	}
+	return ConsoleDestination{mapper: mapper}
 }
 func (d ConsoleDestination) WriteLogs(_ context.Context, logs []integration.Log) error {
 	for _, l := range logs {
-		log.Println(l)
+		log.Println(d.mapper(l))
 	}
 	return nil
 }
@@ -0,0 +1,27 @@
+package dummy
+
+import (
+	"context"
+	"log"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// consoleIndex prints documents of a secondary index to console
+type consoleIndex struct {
+	name string
+}
+
+var _ integration.Indexer = ConsoleDestination{}
+
+// Index returns a DocumentWriter that prints the documents, along with the index name
+func (d ConsoleDestination) Index(name string) (integration.DocumentWriter, error) {
+	return consoleIndex{name: name}, nil
+}
+
+func (i consoleIndex) WriteDocuments(_ context.Context, docs []integration.Document) error {
+	for _, doc := range docs {
+		log.Println(i.name, doc.ID, doc.Body)
+	}
+	return nil
+}
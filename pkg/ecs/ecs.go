@@ -0,0 +1,123 @@
+package ecs
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Version is the version of the Elastic Common Schema the documents follow
+const Version = "8.11.0"
+
+var _ integration.Mapper = Map
+
+// object is a JSON object in an ECS document
+type object map[string]interface{}
+
+// set sets a value in the object, creating the intermediate objects in the path.
+// Empty values are not set.
+func (o object) set(value interface{}, path ...string) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case *string:
+		if v == nil {
+			return
+		}
+		value = *v
+	case *time.Time:
+		if v == nil {
+			return
+		}
+		value = *v
+	}
+	for _, key := range path[:len(path)-1] {
+		child, ok := o[key].(object)
+		if !ok {
+			child = object{}
+			o[key] = child
+		}
+		o = child
+	}
+	o[path[len(path)-1]] = value
+}
+
+// Map converts a log to a document following the Elastic Common Schema (ECS).
+// Fields without an ECS equivalent are stored in the "bugfender" object.
+func Map(l integration.Log) interface{} {
+	doc := object{}
+	doc.set(Version, "ecs", "version")
+	doc.set(l.Time, "@timestamp")
+	doc.set(l.Text, "message")
+
+	doc.set("event", "event", "kind")
+	doc.set("bugfender", "event", "module")
+	doc.set("bugfender.log", "event", "dataset")
+	doc.set(l.Uuid.String(), "event", "id")
+	doc.set(l.AbsoluteTime, "event", "sequence")
+	doc.set(l.Timezone, "event", "timezone")
+	doc.set(l.GapStart, "event", "start")
+	doc.set(l.GapEnd, "event", "end")
+
+	if l.Level.Valid() {
+		doc.set(l.Level.String(), "log", "level")
+		doc.set(l.Level.Severity(), "event", "severity")
+		doc.set(l.Level.SyslogSeverity(), "log", "syslog", "severity", "code")
+		doc.set(l.Level.SyslogSeverityName(), "log", "syslog", "severity", "name")
+	}
+	doc.set(l.Tag, "log", "logger")
+	doc.set(l.File, "log", "origin", "file", "name")
+	if l.Line != 0 {
+		doc.set(l.Line, "log", "origin", "file", "line")
+	}
+	doc.set(l.Method, "log", "origin", "function")
+
+	doc.set(l.DeviceName, "host", "name")
+	doc.set(l.OSVersion, "host", "os", "version")
+	doc.set(l.DeviceUDID, "device", "id")
+	doc.set(l.DeviceType, "device", "model", "identifier")
+
+	doc.set(l.VersionVersion, "service", "version")
+	if id, err := strconv.ParseInt(l.ThreadID, 10, 64); err == nil {
+		doc.set(id, "process", "thread", "id")
+	}
+	doc.set(l.ThreadName, "process", "thread", "name")
+	doc.set(l.URL, "url", "full")
+
+	doc.set(strconv.FormatInt(l.App, 10), "labels", "bugfender_app_id")
+	doc.set(l.VersionBuild, "labels", "app_build")
+	doc.set(l.Language, "labels", "language")
+	doc.set(l.Type, "labels", "log_type")
+
+	// Bugfender specific fields
+	doc.set(l.App, "bugfender", "app_id")
+	doc.set(l.Level, "bugfender", "log_level")
+	doc.set(l.IssueID, "bugfender", "issue", "id")
+	doc.set(l.IssueTitle, "bugfender", "issue", "title")
+	doc.set(l.IssueMarkdown, "bugfender", "issue", "markdown")
+	if l.IssueStatus != nil {
+		doc.set(*l.IssueStatus, "bugfender", "issue", "status")
+	}
+	doc.set(l.ActivityName, "bugfender", "activity", "name")
+	doc.set(l.ActivityStatus, "bugfender", "activity", "status")
+	doc.set(l.ViewControllerName, "bugfender", "view_controller", "name")
+	doc.set(l.ViewControllerTitle, "bugfender", "view_controller", "title")
+	doc.set(l.KeyValueKey, "bugfender", "key_value", "key")
+	doc.set(l.KeyValueValue, "bugfender", "key_value", "value")
+	doc.set(l.InteractionClass, "bugfender", "interaction", "class")
+	doc.set(l.InteractionEventName, "bugfender", "interaction", "event_name")
+	doc.set(l.InteractionSender, "bugfender", "interaction", "sender")
+	doc.set(l.InteractionDetail, "bugfender", "interaction", "detail")
+	doc.set(l.JSXPath, "bugfender", "js_xpath")
+
+	// extra fields are written as they are, unless they would overwrite a field above
+	for k, v := range l.Extra {
+		if _, ok := doc[k]; !ok {
+			doc[k] = v
+		}
+	}
+	return doc
+}
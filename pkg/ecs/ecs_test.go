@@ -0,0 +1,114 @@
+package ecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func str(s string) *string { return &s }
+
+func TestMap(t *testing.T) {
+	logTime := time.Date(2021, 3, 4, 12, 30, 45, 123456000, time.UTC)
+	gapEnd := logTime.Add(time.Minute)
+	status := 1
+	base := integration.Log{
+		Uuid:           uuid.FromStringOrNil("8ac3ed4d-2c66-4d0c-a4e0-6e3e2b1f4c2a"),
+		App:            1234,
+		DeviceUDID:     "2b6b4c3e-9a1f-4bfa-8d7e-0c5f7e8d9a10",
+		DeviceName:     "Pixel 4",
+		DeviceType:     "google Pixel 4",
+		VersionVersion: "1.2.3",
+		VersionBuild:   "45",
+		Language:       "en_US",
+		OSVersion:      "11",
+		Timezone:       "Europe/Madrid",
+		Text:           "Connection established",
+		Method:         "onConnect",
+		File:           "Network.kt",
+		Line:           42,
+		Level:          integration.LevelWarning,
+		Tag:            "network",
+		Time:           logTime,
+		ThreadID:       "17",
+		ThreadName:     "main",
+		AbsoluteTime:   1001,
+		Type:           "log",
+	}
+
+	noLevel := base
+	noLevel.Level = integration.Level(42)
+	noLevel.Line = 0
+	noLevel.ThreadID = "worker-3" // not numeric, so no process.thread.id
+
+	optional := base
+	optional.Level = integration.LevelError
+	optional.URL = "https://example.com/checkout"
+	optional.IssueID = str("issue-1")
+	optional.IssueTitle = str("Crash on checkout")
+	optional.IssueMarkdown = str("**Crash** on checkout")
+	optional.IssueStatus = &status
+	optional.ActivityName = str("CheckoutActivity")
+	optional.ActivityStatus = str("resumed")
+	optional.ViewControllerName = str("CheckoutViewController")
+	optional.ViewControllerTitle = str("Checkout")
+	optional.GapStart = &logTime
+	optional.GapEnd = &gapEnd
+	optional.KeyValueKey = str("cart")
+	optional.KeyValueValue = str("3 items")
+	optional.InteractionClass = str("Button")
+	optional.InteractionEventName = str("onClick")
+	optional.InteractionSender = str("payButton")
+	optional.InteractionDetail = str("id=pay")
+	optional.JSXPath = str("/html/body/button")
+
+	extra := base
+	extra.Level = integration.LevelInfo
+	extra.Extra = map[string]interface{}{
+		"session_id": "s-1",
+		"parsed":     map[string]interface{}{"user": "alice", "attempt": json.Number("2")},
+		"message":    "not overwritten",
+		"host":       "not overwritten",
+	}
+
+	for _, tt := range []struct {
+		name string
+		log  integration.Log
+	}{
+		{"level", base},
+		{"no_level", noLevel},
+		{"optional_fields", optional},
+		{"extra_fields", extra},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(Map(tt.log), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			golden := filepath.Join("testdata", tt.name+".golden.json")
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Map() =\n%s\nwant (%s):\n%s", got, golden, want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v7"
@@ -16,19 +17,41 @@ import (
 
 type Client struct {
 	es          *elasticsearch.Client
-	indexer     esutil.BulkIndexer
+	index       string
+	pipeline    string            // ingest pipeline of the logs
+	pipelines   map[string]string // ingest pipelines by index name
+	mapper      integration.Mapper
 	failureFunc func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem, error) // Per item
 }
 
+// Config contains the parameters to connect to Elasticsearch
+type Config struct {
+	Index     string
+	Addresses []string
+	Username  string
+	Password  string
+	// Mapper converts logs to documents (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+	// Pipeline is the ingest pipeline for logs (default: none)
+	Pipeline string
+	// Pipelines overrides the ingest pipeline by index name, or by app ("app:1234").
+	// Secondary indices only use a pipeline if it's specified here.
+	Pipelines map[string]string
+	// AppID is the Bugfender app the logs belong to, to find its pipeline
+	AppID int64
+	// InstallPipeline installs the bundled pipeline (see BundledPipelineID) on startup
+	InstallPipeline bool
+}
+
 // NewClient creates an ES client with the given parameters
 // It is compulsory to call Close when done.
-func NewClient(index string, addresses []string, username, password string) (*Client, error) {
+func NewClient(config Config) (*Client, error) {
 	// uses Elasticsearch's BulkIndexer utility
 	// example: https://github.com/elastic/go-elasticsearch/blob/v7.10.0/esutil/bulk_indexer_example_test.go
 	es, err := elasticsearch.NewClient(elasticsearch.Config{
-		Addresses:     addresses,
-		Username:      username,
-		Password:      password,
+		Addresses:     config.Addresses,
+		Username:      config.Username,
+		Password:      config.Password,
 		RetryOnStatus: []int{502, 503, 504, 429},
 		RetryBackoff:  func(i int) time.Duration { return time.Duration(i) * 100 * time.Millisecond },
 		MaxRetries:    5,
@@ -36,12 +59,22 @@ func NewClient(index string, addresses []string, username, password string) (*Cl
 	if err != nil {
 		return nil, err
 	}
-	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		Client: es,
-		Index:  index,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("Error creating the indexer: %s", err)
+	ec := &Client{
+		es:        es,
+		pipelines: config.Pipelines,
+	}
+	// check the pipeline is ready before writing anything
+	ctx := context.Background()
+	if config.InstallPipeline {
+		if err := ec.installBundledPipeline(ctx); err != nil {
+			return nil, err
+		}
+	}
+	pipeline := config.logsPipeline()
+	if pipeline != "" {
+		if err := ec.checkPipeline(ctx, pipeline); err != nil {
+			return nil, err
+		}
 	}
 	failureFunc := func(
 		ctx context.Context,
@@ -54,37 +87,78 @@ func NewClient(index string, addresses []string, username, password string) (*Cl
 			log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
 		}
 	}
-	return &Client{
-		es:          es,
-		indexer:     indexer,
-		failureFunc: failureFunc,
-	}, nil
+	mapper := config.Mapper
+	if mapper == nil {
+		mapper = integration.DefaultMapper
+	}
+	ec.index = config.Index
+	ec.pipeline = pipeline
+	ec.mapper = mapper
+	ec.failureFunc = failureFunc
+	return ec, nil
 }
 
-// WriteLogs writes logs to Elasticsearch
+// WriteLogs writes logs to Elasticsearch, and waits until they are written.
+// It returns an error if any of them is not written, so that they are written again.
 func (ec *Client) WriteLogs(ctx context.Context, page []integration.Log) error {
-	for _, l := range page {
-		doc, err := json.Marshal(l)
+	items := make([]esutil.BulkIndexerItem, len(page))
+	for i, l := range page {
+		doc, err := json.Marshal(ec.mapper(l))
 		if err != nil {
 			panic(err) // programming error
 		}
-		err = ec.indexer.Add(
-			ctx,
-			esutil.BulkIndexerItem{
-				Action:     "index",
-				DocumentID: l.Uuid.String(),
-				Body:       bytes.NewReader(doc),
-				OnFailure:  ec.failureFunc,
-			},
-		)
-		if err != nil {
+		items[i] = esutil.BulkIndexerItem{
+			Action:     "index",
+			DocumentID: l.Uuid.String(),
+			Body:       bytes.NewReader(doc),
+			OnFailure:  ec.failureFunc,
+		}
+	}
+	return ec.bulk(ctx, ec.index, ec.pipeline, items)
+}
+
+// bulk writes items to an index with a bulk indexer, and waits until they are written.
+// The failures of the items are logged, and returned as an error.
+func (ec *Client) bulk(ctx context.Context, index, pipeline string, items []esutil.BulkIndexerItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var mu sync.Mutex
+	var flushErr error // last error of the requests, the indexer reports each one twice and the last time with details
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Client:   ec.es,
+		Index:    index,
+		Pipeline: pipeline,
+		OnError: func(_ context.Context, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			flushErr = err
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating the indexer for %s: %s", index, err)
+	}
+	for _, item := range items {
+		if err := indexer.Add(ctx, item); err != nil {
+			_ = indexer.Close(ctx)
 			return err
 		}
 	}
+	if err := indexer.Close(ctx); err != nil {
+		return err
+	}
+	// items whose response couldn't be decoded are neither flushed nor failed
+	if flushed := indexer.Stats().NumFlushed; flushed != uint64(len(items)) {
+		err := fmt.Errorf("writing to %s: %d of %d documents not written", index, uint64(len(items))-flushed, len(items))
+		if flushErr != nil {
+			err = fmt.Errorf("%s: %s", err, flushErr)
+		}
+		return err
+	}
 	return nil
 }
 
-// Close flushes any pending logs and frees resources
-func (ec *Client) Close(ctx context.Context) error {
-	return ec.indexer.Close(ctx)
+// Close frees resources. Logs and documents are already written when WriteLogs and WriteDocuments return.
+func (ec *Client) Close(_ context.Context) error {
+	return nil
 }
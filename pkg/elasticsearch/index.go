@@ -0,0 +1,63 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// documentWriter writes documents to a secondary index
+type documentWriter struct {
+	client   *Client
+	index    string
+	pipeline string
+}
+
+var _ integration.Indexer = &Client{}
+
+// Index returns a DocumentWriter that writes to the given index
+func (ec *Client) Index(name string) (integration.DocumentWriter, error) {
+	pipeline := ec.pipelines[name]
+	if pipeline != "" {
+		if err := ec.checkPipeline(context.Background(), pipeline); err != nil {
+			return nil, err
+		}
+	}
+	return &documentWriter{client: ec, index: name, pipeline: pipeline}, nil
+}
+
+// WriteDocuments writes documents to the index, replacing the documents with the same ID, or combining them with
+// their scripts, and waits until they are written
+func (w *documentWriter) WriteDocuments(ctx context.Context, docs []integration.Document) error {
+	items := make([]esutil.BulkIndexerItem, len(docs))
+	for i, d := range docs {
+		item := esutil.BulkIndexerItem{
+			Action:     "index",
+			DocumentID: d.ID,
+			OnFailure:  w.client.failureFunc,
+		}
+		var body []byte
+		var err error
+		if d.Script != "" {
+			item.Action = "update"
+			item.RetryOnConflict = intPtr(3)
+			body, err = d.ScriptedUpsert()
+		} else {
+			body, err = json.Marshal(d.Body)
+		}
+		if err != nil {
+			return err
+		}
+		item.Body = bytes.NewReader(body)
+		items[i] = item
+	}
+	return w.client.bulk(ctx, w.index, w.pipeline, items)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,95 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// BundledPipelineID is the ID of the ingest pipeline bundled with this tool
+const BundledPipelineID = "bugfender-logs"
+
+// bundledPipeline adds the level name (for documents written by older versions) and the local time of the device
+const bundledPipeline = `{
+  "description": "Bugfender logs: log level names and device local time",
+  "processors": [
+    {
+      "script": {
+        "if": "ctx.log_level instanceof Number && ctx.log_level_name == null",
+        "lang": "painless",
+        "source": "String[] names = new String[] {'debug', 'warning', 'error', 'trace', 'info', 'fatal'}; int level = ctx.log_level; if (level >= 0 && level < names.length) { ctx.log_level_name = names[level]; }"
+      }
+    },
+    {
+      "script": {
+        "if": "ctx.time != null && ctx.timezone != null && ctx.timezone != ''",
+        "lang": "painless",
+        "source": "try { ZonedDateTime t = ZonedDateTime.parse(ctx.time).withZoneSameInstant(ZoneId.of(ctx.timezone)); ctx.local_time = t.format(DateTimeFormatter.ISO_OFFSET_DATE_TIME); ctx.local_hour = t.getHour(); ctx.local_day_of_week = t.getDayOfWeek().getValue(); } catch (Exception e) { }"
+      }
+    }
+  ]
+}`
+
+// ParsePipelines parses a list of pipeline overrides separated by spaces: index=pipeline or app:1234=pipeline
+func ParsePipelines(s string) (map[string]string, error) {
+	pipelines := make(map[string]string)
+	for _, pair := range strings.Fields(s) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid pipeline %q, expected index=pipeline or app:id=pipeline", pair)
+		}
+		pipelines[kv[0]] = kv[1]
+	}
+	return pipelines, nil
+}
+
+// logsPipeline returns the pipeline for the logs index: by index name, by app, or the default one
+func (config *Config) logsPipeline() string {
+	if p, ok := config.Pipelines[config.Index]; ok {
+		return p
+	}
+	if p, ok := config.Pipelines["app:"+strconv.FormatInt(config.AppID, 10)]; ok {
+		return p
+	}
+	return config.Pipeline
+}
+
+// installBundledPipeline creates or replaces the bundled pipeline
+func (ec *Client) installBundledPipeline(ctx context.Context) error {
+	res, err := ec.es.Ingest.PutPipeline(
+		BundledPipelineID,
+		strings.NewReader(bundledPipeline),
+		ec.es.Ingest.PutPipeline.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("installing pipeline %s: %s", BundledPipelineID, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("installing pipeline %s: %s: %s", BundledPipelineID, res.Status(), string(body))
+	}
+	return nil
+}
+
+// checkPipeline returns an error if the pipeline doesn't exist
+func (ec *Client) checkPipeline(ctx context.Context, id string) error {
+	res, err := ec.es.Ingest.GetPipeline(
+		ec.es.Ingest.GetPipeline.WithPipelineID(id),
+		ec.es.Ingest.GetPipeline.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("checking pipeline %s: %s", id, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode == 404 {
+		return fmt.Errorf("pipeline %s does not exist", id)
+	}
+	if res.IsError() {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("checking pipeline %s: %s: %s", id, res.Status(), string(body))
+	}
+	return nil
+}
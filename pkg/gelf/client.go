@@ -0,0 +1,230 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/netconn"
+)
+
+const (
+	// TransportUDP sends each log in a datagram, split in chunks if needed, without confirmation of delivery
+	TransportUDP = "udp"
+	// TransportTCP sends logs over TCP, terminated by null bytes
+	TransportTCP = "tcp"
+	// TransportHTTP sends each log in a request to a GELF HTTP input
+	TransportHTTP = "http"
+)
+
+const (
+	// CompressionGzip compresses UDP messages and HTTP requests with gzip
+	CompressionGzip = "gzip"
+	// CompressionZlib compresses UDP messages with zlib
+	CompressionZlib = "zlib"
+	// CompressionNone doesn't compress
+	CompressionNone = "none"
+)
+
+// DefaultChunkSize is the default maximum size of UDP datagrams, which fits the usual MTU of WANs
+const DefaultChunkSize = 1420
+
+// Client sends logs to Graylog, or other servers with GELF inputs
+type Client struct {
+	address     string
+	transport   string
+	compression string
+	chunkSize   int
+	mapper      integration.Mapper
+	timeout     time.Duration
+	httpClient  *http.Client
+	conn        *netconn.Conn // for UDP and TCP
+}
+
+// Config contains the parameters to send logs with GELF
+type Config struct {
+	// Address is the host and port of the input for UDP and TCP (eg. graylog:12201),
+	// or its URL for HTTP (eg. http://graylog:12201/gelf)
+	Address string
+	// Transport is TransportUDP (default), TransportTCP or TransportHTTP
+	Transport string
+	// Compression is CompressionGzip (default), CompressionZlib (only UDP) or CompressionNone. TCP is not compressed.
+	Compression string
+	// ChunkSize is the maximum size of UDP datagrams (default: DefaultChunkSize)
+	ChunkSize int
+	// Mapper converts logs to the documents whose fields are the additional fields (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+	// Timeout to connect and to send each batch of logs (default: 30s)
+	Timeout time.Duration
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates a GELF client with the given parameters. The connection is established when writing logs.
+// It is compulsory to call Close when done.
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		address:     config.Address,
+		transport:   config.Transport,
+		compression: config.Compression,
+		chunkSize:   config.ChunkSize,
+		mapper:      config.Mapper,
+		timeout:     config.Timeout,
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("an input address is needed")
+	}
+	switch c.transport {
+	case "":
+		c.transport = TransportUDP
+	case TransportUDP, TransportTCP, TransportHTTP:
+	default:
+		return nil, fmt.Errorf("invalid transport %s, expected %s, %s or %s", c.transport,
+			TransportUDP, TransportTCP, TransportHTTP)
+	}
+	switch c.compression {
+	case "":
+		c.compression = CompressionGzip
+	case CompressionGzip, CompressionNone:
+	case CompressionZlib:
+		if c.transport == TransportHTTP {
+			return nil, fmt.Errorf("zlib compression is only supported with UDP")
+		}
+	default:
+		return nil, fmt.Errorf("invalid compression %s, expected %s, %s or %s", c.compression,
+			CompressionGzip, CompressionZlib, CompressionNone)
+	}
+	if c.chunkSize == 0 {
+		c.chunkSize = DefaultChunkSize
+	}
+	if c.chunkSize <= chunkHeaderSize {
+		return nil, fmt.Errorf("chunk size %d too small", c.chunkSize)
+	}
+	if c.mapper == nil {
+		c.mapper = integration.DefaultMapper
+	}
+	if c.timeout == 0 {
+		c.timeout = 30 * time.Second
+	}
+	if c.transport == TransportHTTP {
+		c.httpClient = &http.Client{Timeout: c.timeout}
+	} else {
+		c.conn = netconn.New(netconn.Config{
+			Name:    "GELF input",
+			Network: c.transport,
+			Address: c.address,
+			Timeout: c.timeout,
+		})
+	}
+	return c, nil
+}
+
+// WriteLogs sends logs to the GELF input, connecting first if needed.
+// Over TCP, if the connection is broken, it reconnects and sends the logs again once.
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	messages := make([][]byte, len(logs))
+	for i := range logs {
+		messages[i] = c.encode(&logs[i])
+	}
+	if c.transport == TransportHTTP {
+		for _, m := range messages {
+			if err := c.post(ctx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := c.conn.Write(ctx, func(w io.Writer) error {
+		return c.send(w, messages)
+	})
+	if err != nil {
+		return fmt.Errorf("sending logs to GELF input: %s", err)
+	}
+	return nil
+}
+
+// send writes the messages
+func (c *Client) send(w io.Writer, messages [][]byte) error {
+	if c.transport == TransportUDP {
+		for _, m := range messages {
+			if err := c.sendDatagrams(w, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// null byte framing
+	var frames []byte
+	for _, m := range messages {
+		frames = append(frames, m...)
+		frames = append(frames, 0)
+	}
+	_, err := w.Write(frames)
+	return err
+}
+
+// post sends a message to the HTTP input
+func (c *Client) post(ctx context.Context, message []byte) error {
+	body := message
+	if c.compression == CompressionGzip {
+		var err error
+		if body, err = compress(message, c.compression); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.compression == CompressionGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending logs to GELF input: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	msg, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode/100 == 2 {
+		return nil
+	}
+	return fmt.Errorf("sending logs to GELF input: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+}
+
+// compress compresses data with gzip or zlib
+func compress(data []byte, compression string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if compression == CompressionZlib {
+		w = zlib.NewWriter(&buf)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Close closes the connection, if any
+func (c *Client) Close(_ context.Context) error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
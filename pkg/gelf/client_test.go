@@ -0,0 +1,75 @@
+package gelf
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func TestSendTCP(t *testing.T) {
+	c := &Client{transport: TransportTCP}
+	var w writes
+	if err := c.send(&w, [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":1}\x00{\"b\":2}\x00"; len(w) != 1 || string(w[0]) != want {
+		t.Errorf("got %q, want %q", w, want)
+	}
+}
+
+func TestWriteLogsTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		received <- b
+	}()
+	mapper := func(l integration.Log) interface{} { return map[string]interface{}{"tag": l.Tag} }
+	c, err := NewClient(Config{Address: ln.Addr().String(), Transport: TransportTCP, Mapper: mapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []integration.Log{
+		{DeviceUDID: "udid-1", Level: integration.LevelError, Tag: "net", Text: "first"},
+		{Text: "second\nline"},
+	}
+	if err := c.WriteLogs(context.Background(), logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	frames := strings.Split(string(<-received), "\x00")
+	want := []map[string]interface{}{
+		{"version": "1.1", "host": "udid-1", "short_message": "first", "level": float64(3), "_tag": "net"},
+		{"version": "1.1", "host": "bugfender", "short_message": "second", "full_message": "second\nline",
+			"level": float64(7)},
+	}
+	if len(frames) != len(want)+1 || frames[len(want)] != "" {
+		t.Fatalf("got %q, want %d messages terminated by null bytes", frames, len(want))
+	}
+	for i := range want {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(frames[i]), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("message %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package gelf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// defaultLevel is the level of the logs with an unknown level (informational)
+const defaultLevel = 6
+
+// maxShortMessage is the maximum length of short_message, the rest of the text is only in full_message
+const maxShortMessage = 250
+
+// skippedFields are the fields of the documents that are already the message and the timestamp,
+// in the default format and ECS
+var skippedFields = map[string]bool{"text": true, "time": true, "message": true, "@timestamp": true}
+
+// invalidFieldChars are the characters not allowed in the names of additional fields
+var invalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+// encode encodes a log as a GELF 1.1 message.
+// The host is the device UDID, short_message the first line of the text (up to maxShortMessage characters),
+// full_message the whole text when longer, and level the syslog severity. The fields of the document of the log,
+// flattened with dots, are additional fields.
+func (c *Client) encode(l *integration.Log) []byte {
+	fields := c.additionalFields(l)
+	level := l.Level.SyslogSeverity()
+	if level < 0 {
+		level = defaultLevel
+	}
+	host := l.DeviceUDID
+	if host == "" {
+		host = "bugfender"
+	}
+	short := l.Text
+	if i := strings.IndexAny(short, "\r\n"); i >= 0 {
+		short = short[:i]
+	}
+	if len([]rune(short)) > maxShortMessage {
+		short = string([]rune(short)[:maxShortMessage])
+	}
+	if strings.TrimSpace(short) == "" {
+		short = "-" // short_message can't be empty
+	}
+	fields["version"] = "1.1"
+	fields["host"] = host
+	fields["short_message"] = short
+	if short != l.Text {
+		fields["full_message"] = l.Text
+	}
+	if !l.Time.IsZero() {
+		fields["timestamp"] = json.Number(fmt.Sprintf("%d.%06d", l.Time.Unix(), l.Time.Nanosecond()/1000))
+	}
+	fields["level"] = level
+	b, err := json.Marshal(fields)
+	if err != nil {
+		panic(err) // programming error
+	}
+	return b
+}
+
+// additionalFields returns the fields of the document of the log, prefixed with _.
+// Nested objects are flattened with dots, empty strings are left out and values that aren't strings or numbers
+// are encoded as JSON strings.
+func (c *Client) additionalFields(l *integration.Log) map[string]interface{} {
+	b, err := json.Marshal(c.mapper(*l))
+	if err != nil {
+		panic(err) // programming error
+	}
+	var doc map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		panic(err) // programming error
+	}
+	fields := make(map[string]interface{}, len(doc))
+	var flatten func(prefix string, o map[string]interface{})
+	flatten = func(prefix string, o map[string]interface{}) {
+		for k, v := range o {
+			if prefix == "" && skippedFields[k] {
+				continue
+			}
+			name := prefix + invalidFieldChars.ReplaceAllString(k, "_")
+			switch v := v.(type) {
+			case nil:
+			case map[string]interface{}:
+				flatten(name+".", v)
+			case string:
+				if v != "" {
+					fields["_"+name] = v
+				}
+			case json.Number:
+				fields["_"+name] = v
+			default:
+				s, _ := json.Marshal(v)
+				fields["_"+name] = string(s)
+			}
+		}
+	}
+	flatten("", doc)
+	delete(fields, "_id") // reserved
+	return fields
+}
@@ -0,0 +1,55 @@
+package gelf
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+)
+
+const (
+	// chunkHeaderSize is the size of the header of the chunks: magic bytes, message ID, sequence number and count
+	chunkHeaderSize = 2 + 8 + 1 + 1
+	// maxChunks is the maximum number of chunks of a message
+	maxChunks = 128
+)
+
+// sendDatagrams sends a message over UDP, compressed and split in chunks if it doesn't fit in a datagram.
+// Messages that need more than maxChunks chunks are logged and dropped, because GELF inputs would discard them.
+func (c *Client) sendDatagrams(w io.Writer, message []byte) error {
+	if c.compression != CompressionNone {
+		var err error
+		if message, err = compress(message, c.compression); err != nil {
+			return err
+		}
+	}
+	if len(message) <= c.chunkSize {
+		_, err := w.Write(message)
+		return err
+	}
+	size := c.chunkSize - chunkHeaderSize
+	count := (len(message) + size - 1) / size
+	if count > maxChunks {
+		log.Printf("ERROR: GELF message of %d bytes dropped, it needs more than %d chunks", len(message), maxChunks)
+		return nil
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("generating message ID: %s", err)
+	}
+	chunk := make([]byte, 0, c.chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(message) {
+			end = len(message)
+		}
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, message[i*size:end]...)
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
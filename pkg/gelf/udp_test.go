@@ -0,0 +1,105 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+// writes records the data of each call to Write
+type writes [][]byte
+
+func (w *writes) Write(p []byte) (int, error) {
+	*w = append(*w, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestSendDatagrams(t *testing.T) {
+	c := &Client{compression: CompressionNone, chunkSize: chunkHeaderSize + 4}
+
+	// messages that fit are sent as they are
+	var w writes
+	if err := c.sendDatagrams(&w, []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 1 || string(w[0]) != "1234" {
+		t.Errorf("got %q, want one datagram 1234", w)
+	}
+
+	// longer messages are split in chunks of the same size, with the header
+	w = nil
+	if err := c.sendDatagrams(&w, []byte("0123456789abcdefgh")); err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 5 {
+		t.Fatalf("got %d chunks, want 5", len(w))
+	}
+	id := w[0][2:10]
+	for i, want := range []string{"0123", "4567", "89ab", "cdef", "gh"} {
+		chunk := w[i]
+		if !bytes.Equal(chunk[:2], []byte{0x1e, 0x0f}) {
+			t.Errorf("chunk %d: magic bytes = % x, want 1e 0f", i, chunk[:2])
+		}
+		if !bytes.Equal(chunk[2:10], id) {
+			t.Errorf("chunk %d: message ID = % x, want % x", i, chunk[2:10], id)
+		}
+		if chunk[10] != byte(i) || chunk[11] != 5 {
+			t.Errorf("chunk %d: sequence = %d/%d, want %d/5", i, chunk[10], chunk[11], i)
+		}
+		if got := string(chunk[chunkHeaderSize:]); got != want {
+			t.Errorf("chunk %d: data = %q, want %q", i, got, want)
+		}
+	}
+
+	// each message has its own ID
+	var w2 writes
+	if err := c.sendDatagrams(&w2, []byte("0123456789abcdefgh")); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(w2[0][2:10], id) {
+		t.Error("two messages have the same ID")
+	}
+}
+
+func TestSendDatagramsMaxChunks(t *testing.T) {
+	c := &Client{compression: CompressionNone, chunkSize: chunkHeaderSize + 1}
+	var w writes
+	if err := c.sendDatagrams(&w, bytes.Repeat([]byte("x"), maxChunks)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != maxChunks || w[maxChunks-1][10] != maxChunks-1 || w[maxChunks-1][11] != maxChunks {
+		t.Errorf("got %d chunks, want %d", len(w), maxChunks)
+	}
+
+	// one more chunk is dropped, without an error
+	w = nil
+	if err := c.sendDatagrams(&w, bytes.Repeat([]byte("x"), maxChunks+1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 0 {
+		t.Errorf("got %d chunks, want the message dropped", len(w))
+	}
+}
+
+func TestSendDatagramsCompressed(t *testing.T) {
+	c := &Client{compression: CompressionGzip, chunkSize: DefaultChunkSize}
+	var w writes
+	if err := c.sendDatagrams(&w, []byte(`{"version":"1.1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 1 {
+		t.Fatalf("got %d datagrams, want 1", len(w))
+	}
+	r, err := gzip.NewReader(bytes.NewReader(w[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"version":"1.1"}` {
+		t.Errorf("got %s", b)
+	}
+}
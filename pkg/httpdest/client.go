@@ -0,0 +1,261 @@
+package httpdest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/backoff"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/httpheader"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/signature"
+)
+
+const (
+	// FormatJSON sends each batch as a JSON array of documents
+	FormatJSON = "json"
+	// FormatNDJSON sends each batch as newline-delimited JSON documents
+	FormatNDJSON = "ndjson"
+	// FormatTemplate sends each batch as the output of a Go template
+	FormatTemplate = "template"
+)
+
+// Client posts batches of logs to an HTTP endpoint
+type Client struct {
+	url         string
+	format      string
+	template    *template.Template
+	contentType string
+	headers     map[string]string
+	username    string
+	password    string
+	bearerToken string
+	hmacSecret  []byte
+	batchSize   int
+	batchBytes  int
+	retries     int
+	mapper      integration.Mapper
+	httpClient  *http.Client
+}
+
+// Config contains the parameters to post logs to an HTTP endpoint
+type Config struct {
+	URL string
+	// Format is FormatJSON (default), FormatNDJSON or FormatTemplate
+	Format string
+	// Template is the Go template (text/template) of the body, with FormatTemplate. It's executed with TemplateData,
+	// and the json function encodes a value in JSON, eg. {"count": {{len .Logs}}, "logs": {{json .Logs}}}
+	Template string
+	// ContentType of the requests (default: application/json, or application/x-ndjson with FormatNDJSON)
+	ContentType string
+	// Headers are sent with each request
+	Headers map[string]string
+	// Username and Password authenticate with basic authentication, if Username is not empty
+	Username string
+	Password string
+	// BearerToken is sent in the Authorization header, if not empty
+	BearerToken string
+	// HMACSecret signs the requests, if not empty, like Bugfender signs webhook notifications:
+	// see signature.SignRequest
+	HMACSecret []byte
+	// BatchSize is the maximum number of logs posted in one request (default: 100)
+	BatchSize int
+	// BatchBytes is the maximum size of the JSON documents of the logs posted in one request (default: 1 MB)
+	BatchBytes int
+	// Retries is the number of times a request is retried on 5xx and 429 responses, 0 for none
+	Retries int
+	// Mapper converts logs to the JSON documents posted (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+	// Timeout of each request (default: 30s)
+	Timeout time.Duration
+}
+
+// TemplateData is the data the template is executed with
+type TemplateData struct {
+	// Logs are the documents of the logs of the batch, decoded from JSON (eg. {{range .Logs}}{{.text}}{{end}})
+	Logs []interface{}
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates an HTTP client with the given parameters
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		url:         config.URL,
+		format:      config.Format,
+		contentType: config.ContentType,
+		headers:     config.Headers,
+		username:    config.Username,
+		password:    config.Password,
+		bearerToken: config.BearerToken,
+		hmacSecret:  config.HMACSecret,
+		batchSize:   config.BatchSize,
+		batchBytes:  config.BatchBytes,
+		retries:     config.Retries,
+		mapper:      config.Mapper,
+		httpClient:  &http.Client{Timeout: config.Timeout},
+	}
+	if u, err := url.Parse(c.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid URL %s, expected an http or https URL", c.url)
+	}
+	if c.username != "" && c.bearerToken != "" {
+		return nil, fmt.Errorf("basic authentication and bearer token are exclusive")
+	}
+	switch c.format {
+	case "":
+		c.format = FormatJSON
+	case FormatJSON, FormatNDJSON:
+	case FormatTemplate:
+		var err error
+		c.template, err = template.New("body").Funcs(template.FuncMap{"json": toJSON}).Parse(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %s", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid format %s, expected %s, %s or %s", c.format,
+			FormatJSON, FormatNDJSON, FormatTemplate)
+	}
+	if c.format != FormatTemplate && config.Template != "" {
+		return nil, fmt.Errorf("a template can only be used with the %s format", FormatTemplate)
+	}
+	if c.contentType == "" {
+		c.contentType = "application/json"
+		if c.format == FormatNDJSON {
+			c.contentType = "application/x-ndjson"
+		}
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 100
+	}
+	if c.batchBytes <= 0 {
+		c.batchBytes = 1 << 20
+	}
+	if c.retries < 0 {
+		return nil, fmt.Errorf("invalid number of retries %d", c.retries)
+	}
+	if c.mapper == nil {
+		c.mapper = integration.DefaultMapper
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = 30 * time.Second
+	}
+	return c, nil
+}
+
+// WriteLogs posts logs, in batches of up to BatchSize logs and BatchBytes bytes of documents
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	var docs []json.RawMessage
+	size := 0
+	for i := range logs {
+		doc, err := json.Marshal(c.mapper(logs[i]))
+		if err != nil {
+			panic(err) // programming error
+		}
+		if len(docs) == c.batchSize || (len(docs) > 0 && size+len(doc) > c.batchBytes) {
+			if err := c.post(ctx, docs); err != nil {
+				return err
+			}
+			docs, size = nil, 0
+		}
+		docs = append(docs, doc)
+		size += len(doc)
+	}
+	if len(docs) > 0 {
+		return c.post(ctx, docs)
+	}
+	return nil
+}
+
+// body returns the body of a request with the given documents
+func (c *Client) body(docs []json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	switch c.format {
+	case FormatNDJSON:
+		for _, doc := range docs {
+			buf.Write(doc)
+			buf.WriteByte('\n')
+		}
+	case FormatTemplate:
+		data := TemplateData{Logs: make([]interface{}, len(docs))}
+		for i, doc := range docs {
+			d := json.NewDecoder(bytes.NewReader(doc))
+			d.UseNumber()
+			if err := d.Decode(&data.Logs[i]); err != nil {
+				panic(err) // programming error
+			}
+		}
+		if err := c.template.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("executing template: %s", err)
+		}
+	default:
+		buf.WriteByte('[')
+		for i, doc := range docs {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(doc)
+		}
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), nil
+}
+
+// post posts a batch, retrying it on 5xx and 429 responses
+func (c *Client) post(ctx context.Context, docs []json.RawMessage) error {
+	body, err := c.body(docs)
+	if err != nil {
+		return err
+	}
+	return backoff.Retry(ctx, c.retries, time.Second, 30*time.Second, func() (time.Duration, bool, error) {
+		return c.send(ctx, body)
+	})
+}
+
+// send sends a request, and returns the error and whether it's retryable, with the time to wait if the endpoint
+// says so
+func (c *Client) send(ctx context.Context, body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", c.contentType)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	if len(c.hmacSecret) > 0 {
+		signature.SignRequest(req, c.hmacSecret, body, time.Now())
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("posting logs to %s: %s", req.URL.Host, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	msg, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode/100 == 2 {
+		return 0, false, nil
+	}
+	err = fmt.Errorf("posting logs to %s: %s: %s", req.URL.Host, res.Status, strings.TrimSpace(string(msg)))
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode/100 == 5 {
+		return httpheader.RetryAfter(res.Header), true, err
+	}
+	return 0, false, err
+}
+
+// toJSON encodes a value in JSON, for templates
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
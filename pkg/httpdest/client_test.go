@@ -0,0 +1,194 @@
+package httpdest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// request is a request received by the endpoint
+type request struct {
+	contentType string
+	body        string
+}
+
+// endpoint returns a server that replies to each request with the given status codes in turn (then 204),
+// and records the requests
+func endpoint(t *testing.T, codes ...int) (*httptest.Server, func() []request) {
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		n := len(requests)
+		requests = append(requests, request{req.Header.Get("Content-Type"), string(body)})
+		mu.Unlock()
+		if n < len(codes) {
+			if codes[n] == http.StatusTooManyRequests {
+				rw.Header().Set("Retry-After", "1")
+			}
+			rw.WriteHeader(codes[n])
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+// mapper returns documents with the text and the tag of the logs
+func mapper(l integration.Log) interface{} {
+	return map[string]interface{}{"text": l.Text, "tag": l.Tag}
+}
+
+var logs = []integration.Log{{Text: "first", Tag: "a"}, {Text: `"second"`, Tag: "b"}, {Text: "third", Tag: "c"}}
+
+func TestWriteLogsFormats(t *testing.T) {
+	for _, tt := range []struct {
+		config      Config
+		contentType string
+		bodies      []string
+	}{
+		{
+			config:      Config{},
+			contentType: "application/json",
+			bodies: []string{
+				`[{"tag":"a","text":"first"},{"tag":"b","text":"\"second\""}]`,
+				`[{"tag":"c","text":"third"}]`,
+			},
+		},
+		{
+			config:      Config{Format: FormatNDJSON},
+			contentType: "application/x-ndjson",
+			bodies: []string{
+				"{\"tag\":\"a\",\"text\":\"first\"}\n{\"tag\":\"b\",\"text\":\"\\\"second\\\"\"}\n",
+				"{\"tag\":\"c\",\"text\":\"third\"}\n",
+			},
+		},
+		{
+			config: Config{
+				Format:      FormatTemplate,
+				Template:    `{"count":{{len .Logs}},"texts":[{{range $i, $l := .Logs}}{{if $i}},{{end}}{{json $l.text}}{{end}}]}`,
+				ContentType: "application/vnd.example+json",
+			},
+			contentType: "application/vnd.example+json",
+			bodies:      []string{`{"count":2,"texts":["first","\"second\""]}`, `{"count":1,"texts":["third"]}`},
+		},
+	} {
+		server, requests := endpoint(t)
+		config := tt.config
+		config.URL = server.URL
+		config.BatchSize = 2
+		config.Mapper = mapper
+		c, err := NewClient(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.WriteLogs(context.Background(), logs); err != nil {
+			t.Fatal(err)
+		}
+		got := requests()
+		if len(got) != len(tt.bodies) {
+			t.Fatalf("%s: got %d requests, want %d", c.format, len(got), len(tt.bodies))
+		}
+		for i, r := range got {
+			if r.contentType != tt.contentType {
+				t.Errorf("%s: Content-Type = %s, want %s", c.format, r.contentType, tt.contentType)
+			}
+			if r.body != tt.bodies[i] {
+				t.Errorf("%s: body %d = %q, want %q", c.format, i, r.body, tt.bodies[i])
+			}
+		}
+	}
+}
+
+func TestWriteLogsBatchBytes(t *testing.T) {
+	server, requests := endpoint(t)
+	// the documents are 26, 32 and 26 bytes long
+	c, err := NewClient(Config{URL: server.URL, BatchBytes: 60, Mapper: mapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteLogs(context.Background(), logs); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests(); len(got) != 2 {
+		t.Errorf("got %d requests, want 2", len(got))
+	}
+}
+
+func TestWriteLogsRetries(t *testing.T) {
+	server, requests := endpoint(t, http.StatusTooManyRequests, http.StatusBadGateway)
+	c, err := NewClient(Config{URL: server.URL, Retries: 2, Mapper: mapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := c.WriteLogs(context.Background(), logs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	got := requests()
+	if len(got) != 3 {
+		t.Fatalf("got %d requests, want 3", len(got))
+	}
+	if got[2].body != got[0].body {
+		t.Errorf("retried body = %q, want %q", got[2].body, got[0].body)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the Retry-After time", elapsed)
+	}
+}
+
+func TestWriteLogsErrors(t *testing.T) {
+	for _, tt := range []struct {
+		codes    []int
+		retries  int
+		requests int
+	}{
+		{[]int{http.StatusBadRequest}, 2, 1}, // not retried
+		{[]int{http.StatusInternalServerError, http.StatusInternalServerError}, 1, 2},
+		{[]int{http.StatusServiceUnavailable}, 0, 1},
+	} {
+		server, requests := endpoint(t, tt.codes...)
+		c, err := NewClient(Config{URL: server.URL, Retries: tt.retries, Mapper: mapper})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.WriteLogs(context.Background(), logs[:1]); err == nil {
+			t.Errorf("%v: no error", tt.codes)
+		}
+		if got := len(requests()); got != tt.requests {
+			t.Errorf("%v: got %d requests, want %d", tt.codes, got, tt.requests)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config Config
+		ok     bool
+	}{
+		{"defaults", Config{URL: "https://example.com/logs"}, true},
+		{"invalid URL", Config{URL: "example.com"}, false},
+		{"basic and bearer", Config{URL: "https://example.com", Username: "u", BearerToken: "t"}, false},
+		{"invalid format", Config{URL: "https://example.com", Format: "xml"}, false},
+		{"invalid template", Config{URL: "https://example.com", Format: FormatTemplate, Template: "{{"}, false},
+		{"template without format", Config{URL: "https://example.com", Template: "{{.Logs}}"}, false},
+		{"negative retries", Config{URL: "https://example.com", Retries: -1}, false},
+	} {
+		if _, err := NewClient(tt.config); (err == nil) != tt.ok {
+			t.Errorf("%s: NewClient() error = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
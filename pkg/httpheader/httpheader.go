@@ -0,0 +1,38 @@
+package httpheader
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses headers given as key=value pairs separated by commas, with URL-encoded values, like
+// OTEL_EXPORTER_OTLP_HEADERS (eg. "Authorization=Bearer%20token,X-Source=bugfender%20logs")
+func Parse(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %q: %s", pair, err)
+		}
+		headers[strings.TrimSpace(kv[0])] = value
+	}
+	return headers, nil
+}
+
+// RetryAfter returns the time to wait before retrying a request given in the Retry-After header of the response,
+// in seconds, 0 if none
+func RetryAfter(h http.Header) time.Duration {
+	seconds, _ := strconv.Atoi(h.Get("Retry-After"))
+	return time.Duration(seconds) * time.Second
+}
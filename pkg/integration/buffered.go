@@ -0,0 +1,88 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// BufferedLogWriter is a LogWriter that buffers logs and writes them durably later, eg. to write large objects.
+// The state saved only advances past a page of logs once all the logs buffered are durable, so that no logs
+// are lost if the tool stops. If the logs buffered can't be written durably, the destination discards them
+// and returns ErrBufferDiscarded, and the synchronization resumes from the last page durably written.
+type BufferedLogWriter interface {
+	LogWriter
+	// Buffered returns how many of the last calls to WriteLogs wrote logs that are not durable yet,
+	// 0 if all the logs written are durable
+	Buffered() int
+	// Flush writes the buffered logs durably. It's called when there are no new logs, and before stopping.
+	Flush(context.Context) error
+}
+
+// ErrBufferDiscarded is returned, wrapped, by a BufferedLogWriter that discarded all the logs it buffered,
+// because some of them couldn't be written durably
+var ErrBufferDiscarded = errors.New("buffered logs discarded")
+
+var _ BufferedLogWriter = &processingWriter{}
+
+// Buffered returns how many of the last writes the destination has buffered, 0 if it doesn't buffer logs
+func (w *processingWriter) Buffered() int {
+	if b, ok := w.destination.(BufferedLogWriter); ok {
+		return b.Buffered()
+	}
+	return 0
+}
+
+// Flush flushes the destination, if it buffers logs
+func (w *processingWriter) Flush(ctx context.Context) error {
+	if b, ok := w.destination.(BufferedLogWriter); ok {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the logs buffered by the destination, and advances the state saved if they're durable.
+// It's called from the synchronization goroutine.
+func (i *Integration) flush(ctx context.Context) {
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+	if i.buffered.Buffered() > 0 {
+		if err := i.buffered.Flush(ctx); err != nil {
+			log.Println("Error flushing logs:", err)
+			if errors.Is(err, ErrBufferDiscarded) {
+				i.rewind()
+			}
+		}
+	}
+	i.checkpointBuffered()
+}
+
+// rewind makes the synchronization resume from the state saved, because the destination discarded the logs
+// buffered after it. It must be called with writeMu held, from the synchronization goroutine, which is the only one
+// that fetches pages.
+func (i *Integration) rewind() {
+	log.Println("Synchronizing again the logs discarded by the destination")
+	i.bugfenderClient.rewindLogPages()
+	i.positions = nil
+	i.discarded = false
+}
+
+// checkpointBuffered advances the state saved up to the last write whose logs are all durable.
+// It must be called with writeMu held.
+func (i *Integration) checkpointBuffered() {
+	if i.discarded {
+		return // the state saved can't advance until the pages discarded are written again
+	}
+	buffered := i.buffered.Buffered()
+	if buffered == 0 {
+		i.bugfenderClient.saveCheckpoint()
+		i.positions = nil
+		return
+	}
+	last := len(i.positions) - 1 - buffered // last write not buffered
+	if last < 0 {
+		return
+	}
+	i.bugfenderClient.setCheckpoint(i.positions[last])
+	i.positions = i.positions[last+1:]
+}
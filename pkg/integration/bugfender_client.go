@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -34,14 +35,26 @@ type Client struct {
 	tokenSource *oauth2util.TokenSourceSniffer
 	httpclient  *http.Client
 	nextPageURL url.URL
+	checkpoint  url.URL                    // next page URL once the logs written are durable, which is saved in the state
+	onIdle      func(context.Context)      // called when there are no new logs
+	mu          sync.Mutex                 // protects checkpoint, processors and cursors
+	processors  map[string]json.RawMessage // state of the processors, by key, which is saved in the state
+	cursors     map[string]url.URL         // next page URL of other resources, by name
+	maxPollWait time.Duration              // maximum time to wait between polls for new logs
+	poll        chan struct{}              // requests an immediate poll for new logs
 }
 
 // NewBugfenderClient Creates a Bugfender client to fetch logs from the provided app ID
 func NewBugfenderClient(config *Config, appID int64, state []byte) (*Client, error) {
+	firstPageURL := makeFirstPageURL(config, appID)
 	dm := Client{config: config,
 		appID:       appID,
 		configHash:  hashConfig(config),
-		nextPageURL: makeFirstPageURL(config, appID),
+		nextPageURL: firstPageURL,
+		checkpoint:  firstPageURL,
+		cursors:     make(map[string]url.URL),
+		maxPollWait: 300 * time.Second,
+		poll:        make(chan struct{}, 1),
 	}
 
 	// if state can be restored, restore it
@@ -52,6 +65,11 @@ func NewBugfenderClient(config *Config, appID int64, state []byte) (*Client, err
 		savedState.AppID == appID {
 		refreshToken = savedState.OAuthRefreshToken
 		dm.nextPageURL = url.URL(savedState.NextPageURL)
+		dm.checkpoint = dm.nextPageURL
+		dm.processors = savedState.Processors
+		for name, cursor := range savedState.Cursors {
+			dm.cursors[name] = url.URL(cursor)
+		}
 	}
 
 	// login, reuse token if possible
@@ -105,9 +123,13 @@ func login(config *Config, refreshToken string) (*oauth2util.TokenSourceSniffer,
 }
 
 func makeFirstPageURL(config *Config, appID int64) url.URL {
+	return makeFirstResourcePageURL(config, appID, "logs")
+}
+
+func makeFirstResourcePageURL(config *Config, appID int64, resource string) url.URL {
 	// calculate URL for first page
 	firstRequestURL := *(config.ApiUrl)
-	firstRequestURL.Path = path.Join(firstRequestURL.Path, fmt.Sprintf("/api/app/%d/logs/paginated", appID))
+	firstRequestURL.Path = path.Join(firstRequestURL.Path, fmt.Sprintf("/api/app/%d/%s/paginated", appID, resource))
 	q := firstRequestURL.Query()
 	q.Set("date_range_start", time.Now().Format(time.RFC3339))
 	q.Set("page_size", "10000")
@@ -115,42 +137,153 @@ func makeFirstPageURL(config *Config, appID int64) url.URL {
 	return firstRequestURL
 }
 
+// SetMaxPollWait sets the maximum time to wait between polls for new logs (default: 5 minutes)
+func (dm *Client) SetMaxPollWait(d time.Duration) {
+	dm.maxPollWait = d
+}
+
+// RequestPoll makes a GetNextPage call waiting for new logs poll immediately.
+// It can be called from any goroutine.
+func (dm *Client) RequestPoll() {
+	select {
+	case dm.poll <- struct{}{}:
+	default: // a poll is already requested
+	}
+}
+
 // GetNextPage gets the next page of logs, blocks until there is some data to return
 func (dm *Client) GetNextPage(ctx context.Context) ([]Log, error) {
-	boff := backoff.NewExponential(5*time.Second, 300*time.Second)
+	page, err := dm.GetNextLogPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dm.CommitLogPage(page)
+	return page.Logs, nil
+}
+
+// LogPage is a page of logs
+type LogPage struct {
+	Logs []Log
+	next url.URL
+}
+
+// GetNextLogPage gets the next page of logs, blocks until there is some data to return.
+// The same page is returned again until it's committed with CommitLogPage, so that no logs
+// are lost if they can't be written.
+func (dm *Client) GetNextLogPage(ctx context.Context) (*LogPage, error) {
+	boff := backoff.NewExponential(minDuration(5*time.Second, dm.maxPollWait), dm.maxPollWait)
 	for {
 		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
-		page, err := dm.getLogsPage(ctx, dm.nextPageURL)
+		var page page
+		err := dm.getPage(ctx, dm.nextPageURL, &page)
 		if err != nil {
 			return nil, err
 		}
 		if page.PreviousURL == nil {
-			boff.Wait(ctx)
+			if dm.onIdle != nil {
+				dm.onIdle(ctx)
+			}
+			boff.WaitOrWake(ctx, dm.poll)
 			continue
 		}
-		dm.nextPageURL = url.URL(*page.PreviousURL)
-		return page.Data, ctx.Err()
+		return &LogPage{Logs: page.Data, next: url.URL(*page.PreviousURL)}, ctx.Err()
 	}
 }
 
+// CommitLogPage marks the page as written, so that GetNextLogPage returns the following one
+// and the state saved resumes after it
+func (dm *Client) CommitLogPage(page *LogPage) {
+	dm.advanceLogPage(page)
+	dm.saveCheckpoint()
+}
+
+// advanceLogPage makes GetNextLogPage return the page following page, without changing the state saved
+func (dm *Client) advanceLogPage(page *LogPage) {
+	dm.nextPageURL = page.next
+}
+
+// rewindLogPages makes GetNextLogPage return the pages after the state saved again
+func (dm *Client) rewindLogPages() {
+	dm.mu.Lock()
+	dm.nextPageURL = dm.checkpoint
+	dm.mu.Unlock()
+}
+
+// saveCheckpoint makes the state saved resume after the pages returned so far
+func (dm *Client) saveCheckpoint() {
+	dm.setCheckpoint(dm.nextPageURL)
+}
+
+// setCheckpoint makes the state saved resume from the given page URL
+func (dm *Client) setCheckpoint(next url.URL) {
+	dm.mu.Lock()
+	dm.checkpoint = next
+	dm.mu.Unlock()
+}
+
+// logCursorKey returns the key of the cursor of the logs saved in transactional destinations
+func (dm *Client) logCursorKey() string {
+	return fmt.Sprintf("logs/%d", dm.appID)
+}
+
+// apiHost returns the host of the Bugfender API, saved with the cursors of transactional destinations
+func (dm *Client) apiHost() string {
+	return dm.config.ApiUrl.Host
+}
+
+// restoreLogCursor resumes fetching logs from the given page URL
+func (dm *Client) restoreLogCursor(next url.URL) {
+	dm.nextPageURL = next
+	dm.setCheckpoint(next)
+}
+
+// processorState returns the state of a processor restored from the saved state, nil if there is none
+func (dm *Client) processorState(key string) json.RawMessage {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return dm.processors[key]
+}
+
+// setProcessorState sets the state of a processor to save
+func (dm *Client) setProcessorState(key string, state json.RawMessage) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if dm.processors == nil {
+		dm.processors = make(map[string]json.RawMessage)
+	}
+	dm.processors[key] = state
+}
+
 type saveState struct {
 	ConfigHash        []byte
 	AppID             int64
 	NextPageURL       jsonurl.URL
 	OAuthRefreshToken string
+	Processors        map[string]json.RawMessage `json:",omitempty"`
+	Cursors           map[string]jsonurl.URL     `json:",omitempty"`
 }
 
 // GetState returns the client's state so that it can be restored later
 func (dm *Client) GetState() []byte {
+	dm.mu.Lock()
 	state := saveState{
 		dm.configHash,
 		dm.appID,
-		jsonurl.URL(dm.nextPageURL),
-		dm.tokenSource.CurrentToken.RefreshToken,
+		jsonurl.URL(dm.checkpoint),
+		dm.tokenSource.Current().RefreshToken,
+		dm.processors,
+		nil,
+	}
+	if len(dm.cursors) > 0 {
+		state.Cursors = make(map[string]jsonurl.URL, len(dm.cursors))
+		for name, cursor := range dm.cursors {
+			state.Cursors[name] = jsonurl.URL(cursor)
+		}
 	}
 	b, err := json.Marshal(state)
+	dm.mu.Unlock()
 	if err != nil {
 		panic(err)
 	}
@@ -162,15 +295,16 @@ type page struct {
 	PreviousURL *jsonurl.URL `json:"previous"`
 }
 
-func (dm *Client) getLogsPage(ctx context.Context, url url.URL) (*page, error) {
+// getPage gets a page from the API and decodes it into page
+func (dm *Client) getPage(ctx context.Context, url url.URL, page interface{}) error {
 	req, err := http.NewRequest("GET", url.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("preparing request: %s", err)
+		return fmt.Errorf("preparing request: %s", err)
 	}
 	req = req.WithContext(ctx)
 	resp, err := dm.httpclient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("making request: %s", err)
+		return fmt.Errorf("making request: %s", err)
 	}
 	defer func() {
 		err := resp.Body.Close()
@@ -178,17 +312,23 @@ func (dm *Client) getLogsPage(ctx context.Context, url url.URL) (*page, error) {
 			log.Println(err)
 		}
 	}()
-	var page page
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("unexpected status code: %s. response was: %s", resp.Status, string(bodyBytes))
+		return fmt.Errorf("unexpected status code: %s. response was: %s", resp.Status, string(bodyBytes))
 	}
-	err = json.Unmarshal(bodyBytes, &page)
+	err = json.Unmarshal(bodyBytes, page)
 	if err != nil {
-		return nil, fmt.Errorf("parsing response: %s. response was: %s", err, string(bodyBytes))
+		return fmt.Errorf("parsing response: %s. response was: %s", err, string(bodyBytes))
 	}
-	return &page, nil
+	return nil
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
 }
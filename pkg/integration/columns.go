@@ -0,0 +1,155 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// ColumnKind is the kind of the values of a column of logs
+type ColumnKind int
+
+const (
+	// ColumnUUID values are uuid.UUID
+	ColumnUUID ColumnKind = iota
+	// ColumnTime values are time.Time
+	ColumnTime
+	// ColumnString values are strings
+	ColumnString
+	// ColumnInt64 values are int64
+	ColumnInt64
+	// ColumnInt values are int, eg. levels
+	ColumnInt
+	// ColumnUint64 values are uint64
+	ColumnUint64
+	// ColumnSeverity values are severities of levels, from 0 to 7, as int
+	ColumnSeverity
+	// ColumnJSON values are JSON objects, encoded as []byte
+	ColumnJSON
+)
+
+// Column is a column of a table of logs, eg. in a database or a columnar file
+type Column struct {
+	Name  string
+	Type  string                   // type of the column in the destination
+	Value func(l *Log) interface{} // nil for null values
+}
+
+// ColumnType returns the type in a destination of the column with the given name, kind and nullability,
+// and the conversion of its values to the values written to the destination (nil to write them as they are)
+type ColumnType func(name string, kind ColumnKind, nullable bool) (string, func(interface{}) interface{})
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// Columns returns the columns of a table of logs, with the types of a destination: the fields of Log, with their
+// JSON names (dots replaced by underscores), followed by the fields derived from the level and the extra fields
+// encoded as a JSON object. Pointer fields, the fields derived from the level and the extra fields are nullable.
+func Columns(columnType ColumnType) []Column {
+	var columns []Column
+	t := reflect.TypeOf(Log{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		columns = append(columns, fieldColumn(strings.Replace(name, ".", "_", -1), i, f.Type, columnType))
+	}
+	return append(columns,
+		newColumn("log_level_name", ColumnString, true, columnType, func(l *Log) interface{} {
+			if !l.Level.Valid() {
+				return nil
+			}
+			return l.Level.String()
+		}),
+		newColumn("severity", ColumnSeverity, true, columnType, func(l *Log) interface{} {
+			if !l.Level.Valid() {
+				return nil
+			}
+			return l.Level.Severity()
+		}),
+		newColumn("syslog_severity", ColumnSeverity, true, columnType, func(l *Log) interface{} {
+			if !l.Level.Valid() {
+				return nil
+			}
+			return l.Level.SyslogSeverity()
+		}),
+		newColumn("extra", ColumnJSON, true, columnType, extraValue),
+	)
+}
+
+// fieldColumn returns the column of a field of Log. Pointer fields are nullable.
+func fieldColumn(name string, index int, t reflect.Type, columnType ColumnType) Column {
+	nullable := t.Kind() == reflect.Ptr
+	if nullable {
+		t = t.Elem()
+	}
+	var kind ColumnKind
+	var get func(reflect.Value) interface{}
+	switch {
+	case t == uuidType:
+		kind, get = ColumnUUID, reflect.Value.Interface
+	case t == timeType:
+		kind, get = ColumnTime, reflect.Value.Interface
+	case t.Kind() == reflect.String:
+		kind, get = ColumnString, func(v reflect.Value) interface{} { return v.String() }
+	case t.Kind() == reflect.Int64:
+		kind, get = ColumnInt64, func(v reflect.Value) interface{} { return v.Int() }
+	case t.Kind() == reflect.Int: // includes Level
+		kind, get = ColumnInt, func(v reflect.Value) interface{} { return int(v.Int()) }
+	case t.Kind() == reflect.Uint64:
+		kind, get = ColumnUint64, func(v reflect.Value) interface{} { return v.Uint() }
+	default:
+		panic(fmt.Sprintf("field %s: type %s not supported in columns", name, t)) // programming error
+	}
+	return newColumn(name, kind, nullable, columnType, func(l *Log) interface{} {
+		v := reflect.ValueOf(l).Elem().Field(index)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		return get(v)
+	})
+}
+
+// newColumn returns a column with the type of the destination, and the given values converted
+func newColumn(name string, kind ColumnKind, nullable bool, columnType ColumnType,
+	value func(l *Log) interface{}) Column {
+	typ, convert := columnType(name, kind, nullable)
+	if convert == nil {
+		return Column{Name: name, Type: typ, Value: value}
+	}
+	return Column{
+		Name: name,
+		Type: typ,
+		Value: func(l *Log) interface{} {
+			v := value(l)
+			if v == nil {
+				return nil
+			}
+			return convert(v)
+		},
+	}
+}
+
+// extraValue encodes the extra fields that don't duplicate a column as a JSON object, like the JSON documents
+func extraValue(l *Log) interface{} {
+	extra := l.ExtraFields()
+	if len(extra) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(extra)
+	if err != nil {
+		panic(err) // programming error
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ScriptTimeFormat formats times so that they can be compared as strings in the scripts of documents
+const ScriptTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// Document is a document derived from the logs (eg. a summary), written to a secondary index
+type Document struct {
+	// ID identifies the document, writing a document with the same ID replaces it
+	ID   string
+	Body interface{}
+	// Script, if not empty, combines the document with the existing one with the same ID instead of replacing it.
+	// It's a painless script of Elasticsearch and OpenSearch, which receives Body as params and ctx._source as
+	// the existing document (empty if none).
+	Script string
+}
+
+// DocumentWriter writes documents to a secondary index
+type DocumentWriter interface {
+	WriteDocuments(context.Context, []Document) error
+}
+
+// Indexer is implemented by destinations that can write documents to secondary indices
+type Indexer interface {
+	// Index returns a DocumentWriter that writes to the index with the given name
+	Index(name string) (DocumentWriter, error)
+}
+
+// ScriptedUpsert returns the body of a bulk update of Elasticsearch and OpenSearch that runs the script of the
+// document, creating the document if it doesn't exist
+func (d *Document) ScriptedUpsert() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"scripted_upsert": true,
+		"upsert":          map[string]interface{}{},
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": d.Script,
+			"params": d.Body,
+		},
+	})
+}
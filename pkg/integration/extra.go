@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// UnknownFieldsProcessor handles the fields returned by the Bugfender API that are unknown to this tool,
+// which are kept in Log.Extra. They are passed through to the destination, optionally with a prefix.
+// It must run before any other processor that adds fields to Log.Extra.
+type UnknownFieldsProcessor struct {
+	prefix  string
+	verbose bool
+	seen    map[string]bool
+}
+
+var _ LogProcessor = &UnknownFieldsProcessor{}
+
+// NewUnknownFieldsProcessor creates an UnknownFieldsProcessor that adds prefix to the name of the unknown fields.
+// If verbose, a warning is printed the first time each unknown field is seen.
+// The prefix can't be the beginning of the name of a known field, because prefixed fields could take that name.
+func NewUnknownFieldsProcessor(prefix string, verbose bool) (*UnknownFieldsProcessor, error) {
+	for _, fields := range []map[string]bool{knownFields, derivedFields} {
+		for name := range fields {
+			if prefix != "" && strings.HasPrefix(name, prefix) {
+				return nil, fmt.Errorf("invalid prefix %q, unknown fields could take the name of the field %q", prefix, name)
+			}
+		}
+	}
+	return &UnknownFieldsProcessor{
+		prefix:  prefix,
+		verbose: verbose,
+		seen:    make(map[string]bool),
+	}, nil
+}
+
+// ProcessLogs prefixes the unknown fields of the logs, in place
+func (p *UnknownFieldsProcessor) ProcessLogs(_ context.Context, logs []Log) ([]Log, error) {
+	for i := range logs {
+		l := &logs[i]
+		if len(l.Extra) == 0 {
+			continue
+		}
+		for k := range l.Extra {
+			if p.verbose && !p.seen[k] {
+				log.Printf("WARNING: unknown field %q received from Bugfender, writing it as %q", k, p.prefix+k)
+			}
+			p.seen[k] = true
+		}
+		if p.prefix == "" {
+			continue
+		}
+		prefixed := make(map[string]interface{}, len(l.Extra))
+		for k, v := range l.Extra {
+			prefixed[p.prefix+k] = v
+		}
+		l.Extra = prefixed
+	}
+	return logs, nil
+}
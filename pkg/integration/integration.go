@@ -2,8 +2,12 @@ package integration
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/backoff"
@@ -14,20 +18,55 @@ type Integration struct {
 	destination     LogWriter
 	verbose         bool
 	stateFile       string
+	resources       map[Resource]DocumentWriter
+	writeMu         sync.Mutex             // serializes writes to the destination
+	buffered        BufferedLogWriter      // the destination, if it buffers logs
+	positions       []url.URL              // next page URL after each write the destination may still buffer
+	discarded       bool                   // the destination discarded the logs buffered, and the pages aren't rewound yet
+	transactional   TransactionalLogWriter // the destination, if it saves the cursor with the logs
+	stateful        []StatefulProcessor    // the processors whose state is saved
 }
 
 type LogWriter interface {
 	WriteLogs(context.Context, []Log) error
 }
 
+var _ LogWriter = &Integration{}
+
 // New creates a new integration from the bugfenderClient to the destination
 func New(bugfenderClient *Client, destination LogWriter, verbose bool, stateFile string) (*Integration, error) {
-	return &Integration{
+	i := &Integration{
 		bugfenderClient: bugfenderClient,
 		destination:     destination,
 		verbose:         verbose,
 		stateFile:       stateFile,
-	}, nil
+		stateful:        statefulProcessors(destination),
+		resources:       make(map[Resource]DocumentWriter),
+	}
+	for _, p := range i.stateful {
+		if state := bugfenderClient.processorState(p.StateKey()); state != nil {
+			if err := p.RestoreState(state); err != nil {
+				return nil, fmt.Errorf("restoring the state of %s: %s", p.StateKey(), err)
+			}
+		}
+	}
+	// the processing writer implements the optional interfaces for any destination, so check the destination itself
+	if _, ok := unwrap(destination).(BufferedLogWriter); ok {
+		i.buffered = destination.(BufferedLogWriter)
+		bugfenderClient.onIdle = i.flush
+	}
+	if _, ok := unwrap(destination).(TransactionalLogWriter); ok {
+		i.transactional = destination.(TransactionalLogWriter)
+		if err := i.restoreCursor(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return i, nil
+}
+
+// AddResource synchronizes a Bugfender resource, other than logs, to the given destination
+func (i *Integration) AddResource(r Resource, destination DocumentWriter) {
+	i.resources[r] = destination
 }
 
 // Sync loops synchronizing forever, until cancelled
@@ -37,6 +76,21 @@ func (i *Integration) Sync(ctx context.Context, retries uint) error {
 		log.Println("Sync started, press Ctrl-C to stop")
 	}
 	defer i.saveState()
+	if i.buffered != nil {
+		defer i.flush(context.Background())
+	}
+	// other resources are synchronized in the background
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+	for r, destination := range i.resources {
+		wg.Add(1)
+		go func(r Resource, destination DocumentWriter) {
+			defer wg.Done()
+			i.syncResource(ctx, r, destination)
+		}(r, destination)
+	}
 	nextStateSave := time.Now()
 	for ctx.Err() == nil {
 		boff := backoff.NewExponential(5*time.Second, 300*time.Second)
@@ -66,30 +120,128 @@ func (i *Integration) Sync(ctx context.Context, retries uint) error {
 // syncOnePage synchronizes one page of logs, returns error if something failed
 func (i *Integration) syncOnePage(ctx context.Context) error {
 	// get a page from Bugfender
-	logs, err := i.bugfenderClient.GetNextPage(ctx)
+	page, err := i.bugfenderClient.GetNextLogPage(ctx)
 	if err != nil {
 		return err
 	}
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
-	// put it in the destination
-	err = i.destination.WriteLogs(ctx, logs)
+	// put it in the destination, and only then move on to the next page
+	err = i.writeLogs(ctx, page.Logs, page)
 	if err != nil {
 		return err
 	}
 	if i.verbose {
-		log.Printf("Wrote %d logs", len(logs))
+		log.Printf("Wrote %d logs", len(page.Logs))
 	}
 	return ctx.Err()
 }
 
+// WriteLogs writes logs obtained by other means (eg. webhooks) to the destination.
+// It can be called from any goroutine, writes are serialized with the synchronization.
+func (i *Integration) WriteLogs(ctx context.Context, logs []Log) error {
+	return i.writeLogs(ctx, logs, nil)
+}
+
+// writeLogs writes logs to the destination, and then commits the page they come from, if any.
+// Transactional destinations save the position after the page along with its logs.
+func (i *Integration) writeLogs(ctx context.Context, logs []Log, page *LogPage) error {
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+	if i.discarded && page != nil {
+		// the page comes after the logs discarded by a write of other logs, which are fetched again instead
+		i.rewind()
+		return nil
+	}
+	var err error
+	if i.transactional != nil && page != nil {
+		cursor := Cursor{APIHost: i.bugfenderClient.apiHost(), NextPage: page.next.String()}
+		err = i.transactional.WriteLogsWithCursor(ctx, logs, i.bugfenderClient.logCursorKey(), cursor)
+	} else {
+		err = i.destination.WriteLogs(ctx, logs)
+	}
+	if err != nil {
+		if i.buffered != nil && errors.Is(err, ErrBufferDiscarded) {
+			if page != nil {
+				i.rewind()
+			} else {
+				i.discarded = true // rewound by the synchronization goroutine, with its next page
+			}
+		}
+		return err
+	}
+	if i.buffered == nil {
+		if page != nil {
+			i.bugfenderClient.CommitLogPage(page)
+		}
+		return nil
+	}
+	if page != nil {
+		i.bugfenderClient.advanceLogPage(page)
+	}
+	i.positions = append(i.positions, i.bugfenderClient.nextPageURL)
+	i.checkpointBuffered()
+	return nil
+}
+
+// RequestPoll makes the synchronization poll for new logs immediately, instead of waiting.
+// It can be called from any goroutine.
+func (i *Integration) RequestPoll() {
+	i.bugfenderClient.RequestPoll()
+}
+
+// syncResource synchronizes a resource until cancelled, retrying forever on errors
+func (i *Integration) syncResource(ctx context.Context, r Resource, destination DocumentWriter) {
+	boff := backoff.NewExponential(5*time.Second, 300*time.Second)
+	for ctx.Err() == nil {
+		page, err := i.bugfenderClient.GetNextResourcePage(ctx, r)
+		if err == nil && page != nil {
+			err = destination.WriteDocuments(ctx, page.Documents)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println("Error synchronizing", r.Name, "error:", err)
+			}
+			boff.Wait(ctx)
+			continue
+		}
+		if page == nil { // no new data yet
+			boff.Wait(ctx)
+			continue
+		}
+		i.bugfenderClient.CommitResourcePage(page)
+		boff = backoff.NewExponential(5*time.Second, 300*time.Second)
+		if i.verbose {
+			log.Printf("Wrote %d %s", len(page.Documents), r.Name)
+		}
+	}
+}
+
 func (i *Integration) saveState() {
 	if i.verbose {
 		log.Println("Saving state")
 	}
+	i.saveProcessorStates()
 	err := ioutil.WriteFile(i.stateFile, i.bugfenderClient.GetState(), 0600)
 	if err != nil {
 		log.Println("error saving state file:", err)
 	}
 }
+
+// saveProcessorStates sets the state of the stateful processors in the state to save
+func (i *Integration) saveProcessorStates() {
+	if len(i.stateful) == 0 {
+		return
+	}
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+	for _, p := range i.stateful {
+		state, err := p.SaveState()
+		if err != nil {
+			log.Printf("error saving the state of %s: %s", p.StateKey(), err)
+			continue
+		}
+		i.bugfenderClient.setProcessorState(p.StateKey(), state)
+	}
+}
@@ -0,0 +1,442 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/oauth2util"
+)
+
+// plainWriter is a destination that writes logs durably right away
+type plainWriter struct {
+	writes int
+	err    error
+}
+
+func (w *plainWriter) WriteLogs(_ context.Context, _ []Log) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.writes++
+	return nil
+}
+
+// bufferedWriter is a destination that buffers the logs of the last pending writes
+type bufferedWriter struct {
+	plainWriter
+	pending int
+	discard bool // discard the logs buffered in the next write or flush
+}
+
+func (w *bufferedWriter) WriteLogs(ctx context.Context, logs []Log) error {
+	if err := w.discarded(); err != nil {
+		return err
+	}
+	if err := w.plainWriter.WriteLogs(ctx, logs); err != nil {
+		return err
+	}
+	w.pending++
+	return nil
+}
+
+func (w *bufferedWriter) Buffered() int { return w.pending }
+
+func (w *bufferedWriter) Flush(_ context.Context) error {
+	if err := w.discarded(); err != nil {
+		return err
+	}
+	w.pending = 0
+	return nil
+}
+
+func (w *bufferedWriter) discarded() error {
+	if !w.discard {
+		return nil
+	}
+	w.discard = false
+	w.pending = 0
+	return fmt.Errorf("%w: disk full", ErrBufferDiscarded)
+}
+
+// transactionalWriter is a destination that saves cursors with the logs
+type transactionalWriter struct {
+	plainWriter
+	cursors map[string]Cursor
+}
+
+func (w *transactionalWriter) WriteLogsWithCursor(ctx context.Context, logs []Log, key string, cursor Cursor) error {
+	if err := w.WriteLogs(ctx, logs); err != nil {
+		return err
+	}
+	w.cursors[key] = cursor
+	return nil
+}
+
+func (w *transactionalWriter) Cursor(_ context.Context, key string) (Cursor, error) {
+	return w.cursors[key], nil
+}
+
+// nopProcessor leaves the logs as they are
+type nopProcessor struct{}
+
+func (nopProcessor) ProcessLogs(_ context.Context, logs []Log) ([]Log, error) { return logs, nil }
+
+// countingProcessor counts the logs processed, and saves the count in its state
+type countingProcessor struct {
+	count int
+}
+
+func (p *countingProcessor) ProcessLogs(_ context.Context, logs []Log) ([]Log, error) {
+	p.count += len(logs)
+	return logs, nil
+}
+
+func (p *countingProcessor) StateKey() string { return "count" }
+
+func (p *countingProcessor) SaveState() (json.RawMessage, error) { return json.Marshal(p.count) }
+
+func (p *countingProcessor) RestoreState(state json.RawMessage) error {
+	return json.Unmarshal(state, &p.count)
+}
+
+func pageURL(n string) url.URL {
+	return url.URL{Scheme: "https", Host: "dashboard.bugfender.com", Path: "/api/app/1/logs", RawQuery: "page=" + n}
+}
+
+func newTestClient() *Client {
+	first := pageURL("0")
+	config := &Config{ApiUrl: &url.URL{Scheme: "https", Host: "dashboard.bugfender.com"}}
+	return &Client{config: config, appID: 1, nextPageURL: first, checkpoint: first, cursors: make(map[string]url.URL)}
+}
+
+func logPage(n string) *LogPage {
+	return &LogPage{Logs: []Log{{Text: "log " + n}}, next: pageURL(n)}
+}
+
+func TestNewOptionalInterfaces(t *testing.T) {
+	tests := []struct {
+		name          string
+		destination   LogWriter
+		buffered      bool
+		transactional bool
+	}{
+		{"plain", &plainWriter{}, false, false},
+		{"plain with processors", WithProcessors(&plainWriter{}, nopProcessor{}), false, false},
+		{"buffered with processors", WithProcessors(&bufferedWriter{}, nopProcessor{}), true, false},
+		{"transactional with processors",
+			WithProcessors(&transactionalWriter{cursors: map[string]Cursor{}}, nopProcessor{}), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := New(newTestClient(), tt.destination, false, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (i.buffered != nil) != tt.buffered {
+				t.Errorf("buffered = %v, want %v", i.buffered != nil, tt.buffered)
+			}
+			if (i.transactional != nil) != tt.transactional {
+				t.Errorf("transactional = %v, want %v", i.transactional != nil, tt.transactional)
+			}
+		})
+	}
+}
+
+func TestCommitAfterWrite(t *testing.T) {
+	destination := &plainWriter{}
+	client := newTestClient()
+	i, err := New(client, destination, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	destination.err = errors.New("unavailable")
+	if err := i.writeLogs(ctx, logPage("1").Logs, logPage("1")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if client.nextPageURL != pageURL("0") || client.checkpoint != pageURL("0") {
+		t.Fatalf("failed page committed: next %s, checkpoint %s", client.nextPageURL.String(), client.checkpoint.String())
+	}
+
+	destination.err = nil
+	if err := i.writeLogs(ctx, logPage("1").Logs, logPage("1")); err != nil {
+		t.Fatal(err)
+	}
+	if client.nextPageURL != pageURL("1") || client.checkpoint != pageURL("1") {
+		t.Fatalf("page not committed: next %s, checkpoint %s", client.nextPageURL.String(), client.checkpoint.String())
+	}
+}
+
+func TestCheckpointBuffered(t *testing.T) {
+	destination := &bufferedWriter{}
+	client := newTestClient()
+	i, err := New(client, WithProcessors(destination, nopProcessor{}), false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// three pages buffered: the next page advances, the checkpoint doesn't
+	for _, n := range []string{"1", "2", "3"} {
+		if err := i.writeLogs(ctx, logPage(n).Logs, logPage(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.nextPageURL != pageURL("3") {
+		t.Errorf("next page %s, want page 3", client.nextPageURL.String())
+	}
+	if client.checkpoint != pageURL("0") {
+		t.Errorf("checkpoint %s, want page 0", client.checkpoint.String())
+	}
+	if len(i.positions) != 3 {
+		t.Errorf("%d positions, want 3", len(i.positions))
+	}
+
+	// the destination uploads the first two pages: the checkpoint is after page 2
+	destination.pending = 1
+	i.checkpointBuffered()
+	if client.checkpoint != pageURL("2") {
+		t.Errorf("checkpoint %s, want page 2", client.checkpoint.String())
+	}
+	if len(i.positions) != 1 || i.positions[0] != pageURL("3") {
+		t.Errorf("positions %v, want page 3", i.positions)
+	}
+
+	// a failed write changes nothing
+	destination.err = errors.New("unavailable")
+	if err := i.writeLogs(ctx, logPage("4").Logs, logPage("4")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if client.nextPageURL != pageURL("3") || len(i.positions) != 1 {
+		t.Errorf("failed write advanced: next %s, %d positions", client.nextPageURL.String(), len(i.positions))
+	}
+
+	// logs written by other means (eg. webhooks) are buffered at the current position
+	destination.err = nil
+	if err := i.WriteLogs(ctx, []Log{{Text: "webhook"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(i.positions) != 2 || i.positions[1] != pageURL("3") {
+		t.Errorf("positions %v, want page 3 twice", i.positions)
+	}
+
+	// flushing makes everything durable
+	i.flush(ctx)
+	if client.checkpoint != pageURL("3") {
+		t.Errorf("checkpoint %s, want page 3", client.checkpoint.String())
+	}
+	if len(i.positions) != 0 {
+		t.Errorf("%d positions after flushing, want 0", len(i.positions))
+	}
+}
+
+func TestRewindDiscarded(t *testing.T) {
+	destination := &bufferedWriter{}
+	client := newTestClient()
+	i, err := New(client, destination, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	write := func(n string) error { return i.writeLogs(ctx, logPage(n).Logs, logPage(n)) }
+	for _, n := range []string{"1", "2"} {
+		if err := write(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	destination.pending = 1
+	i.checkpointBuffered()
+
+	// a write that discards the logs buffered fetches the pages after the checkpoint again
+	destination.discard = true
+	if err := write("3"); !errors.Is(err, ErrBufferDiscarded) {
+		t.Fatalf("got error %v, want ErrBufferDiscarded", err)
+	}
+	if client.nextPageURL != pageURL("1") || client.checkpoint != pageURL("1") || len(i.positions) != 0 {
+		t.Errorf("not rewound: next %s, checkpoint %s, %d positions", client.nextPageURL.String(),
+			client.checkpoint.String(), len(i.positions))
+	}
+	if err := write("2"); err != nil {
+		t.Fatal(err)
+	}
+	i.flush(ctx)
+	if client.checkpoint != pageURL("2") {
+		t.Errorf("checkpoint %s, want page 2", client.checkpoint.String())
+	}
+
+	// so does a flush
+	if err := write("3"); err != nil {
+		t.Fatal(err)
+	}
+	destination.discard = true
+	i.flush(ctx)
+	if client.nextPageURL != pageURL("2") || client.checkpoint != pageURL("2") {
+		t.Errorf("not rewound: next %s, checkpoint %s", client.nextPageURL.String(), client.checkpoint.String())
+	}
+
+	// with logs written by other means, the page fetched meanwhile is skipped and fetched again
+	if err := write("3"); err != nil {
+		t.Fatal(err)
+	}
+	destination.discard = true
+	if err := i.WriteLogs(ctx, []Log{{Text: "webhook"}}); !errors.Is(err, ErrBufferDiscarded) {
+		t.Fatalf("got error %v, want ErrBufferDiscarded", err)
+	}
+	if err := i.WriteLogs(ctx, []Log{{Text: "webhook"}}); err != nil {
+		t.Fatal(err)
+	}
+	i.checkpointBuffered()
+	if client.checkpoint != pageURL("2") {
+		t.Errorf("checkpoint %s advanced before rewinding, want page 2", client.checkpoint.String())
+	}
+	if err := write("4"); err != nil {
+		t.Fatal(err)
+	}
+	if client.nextPageURL != pageURL("2") || destination.writes != 6 {
+		t.Errorf("page written instead of rewinding: next %s", client.nextPageURL.String())
+	}
+}
+
+func TestTransactionalCursor(t *testing.T) {
+	destination := &transactionalWriter{cursors: map[string]Cursor{}}
+	ctx := context.Background()
+
+	// no cursor saved: start from the state
+	client := newTestClient()
+	i, err := New(client, WithProcessors(destination, nopProcessor{}), false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.nextPageURL != pageURL("0") {
+		t.Errorf("next page %s, want page 0", client.nextPageURL.String())
+	}
+	if err := i.writeLogs(ctx, logPage("1").Logs, logPage("1")); err != nil {
+		t.Fatal(err)
+	}
+	next := pageURL("1")
+	want := Cursor{APIHost: "dashboard.bugfender.com", NextPage: next.String()}
+	if cursor := destination.cursors["logs/1"]; cursor != want {
+		t.Errorf("cursor %v saved, want %v", cursor, want)
+	}
+	if client.nextPageURL != pageURL("1") || client.checkpoint != pageURL("1") {
+		t.Errorf("page not committed: next %s, checkpoint %s", client.nextPageURL.String(), client.checkpoint.String())
+	}
+
+	// logs written by other means don't change the cursor
+	if err := i.WriteLogs(ctx, []Log{{Text: "webhook"}}); err != nil {
+		t.Fatal(err)
+	}
+	if cursor := destination.cursors["logs/1"]; cursor != want {
+		t.Errorf("cursor %v saved, want %v", cursor, want)
+	}
+
+	// the cursor saved in the destination takes precedence over the state
+	client = newTestClient()
+	if _, err := New(client, destination, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if client.nextPageURL != pageURL("1") || client.checkpoint != pageURL("1") {
+		t.Errorf("cursor not restored: next %s, checkpoint %s", client.nextPageURL.String(), client.checkpoint.String())
+	}
+
+	// cursors of another API host are refused, even with the same app ID
+	for _, host := range []string{"bugfender.example.com", ""} {
+		destination.cursors["logs/1"] = Cursor{APIHost: host, NextPage: next.String()}
+		if _, err := New(newTestClient(), destination, false, ""); err == nil {
+			t.Errorf("expected an error with a cursor of the API at %q", host)
+		}
+	}
+
+	// invalid cursors are reported
+	destination.cursors["logs/1"] = Cursor{APIHost: "dashboard.bugfender.com", NextPage: "%zz"}
+	if _, err := New(newTestClient(), destination, false, ""); err == nil {
+		t.Error("expected an error with an invalid cursor")
+	}
+}
+
+func TestProcessorState(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient()
+	processor := &countingProcessor{}
+	i, err := New(client, WithProcessors(&plainWriter{}, nopProcessor{}, processor), false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.writeLogs(ctx, logPage("1").Logs, logPage("1")); err != nil {
+		t.Fatal(err)
+	}
+	i.saveProcessorStates()
+	if state := string(client.processorState("count")); state != "1" {
+		t.Errorf("state %q saved, want 1", state)
+	}
+
+	// the state is restored when the integration is created
+	restored := &countingProcessor{}
+	if _, err := New(client, WithProcessors(&plainWriter{}, restored), false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if restored.count != 1 {
+		t.Errorf("count %d restored, want 1", restored.count)
+	}
+
+	// invalid states are reported
+	client.setProcessorState("count", json.RawMessage(`"x"`))
+	if _, err := New(client, WithProcessors(&plainWriter{}, &countingProcessor{}), false, ""); err == nil {
+		t.Error("expected an error with an invalid state")
+	}
+}
+
+func TestResourceCursors(t *testing.T) {
+	ctx := context.Background()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Query().Get("page") {
+		case "": // first page
+			_, _ = fmt.Fprintf(rw, `{"data":[{"udid":"d1","name":"phone"}],"previous":"%s%s?page=2"}`,
+				server.URL, req.URL.Path)
+		default: // no new items yet
+			_, _ = fmt.Fprint(rw, `{"data":[],"previous":null}`)
+		}
+	}))
+	defer server.Close()
+	apiURL, _ := url.Parse(server.URL)
+	client := newTestClient()
+	client.config = &Config{ApiUrl: apiURL}
+	client.httpclient = server.Client()
+	client.tokenSource = oauth2util.NewTokenSourceSniffer(nil, &oauth2.Token{RefreshToken: "refresh"})
+
+	page, err := client.GetNextResourcePage(ctx, ResourceDevices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Documents) != 1 || page.Documents[0].ID != "d1" {
+		t.Fatalf("got documents %v, want d1", page.Documents)
+	}
+	// the cursor only moves on when the page is committed
+	if cursor := client.cursors["devices"]; cursor.Path != "/api/app/1/devices/paginated" {
+		t.Errorf("cursor %s before the commit, want the first page", cursor.String())
+	}
+	client.CommitResourcePage(page)
+	if page, err := client.GetNextResourcePage(ctx, ResourceDevices); err != nil || page != nil {
+		t.Errorf("got page %v, error %v, want no new page", page, err)
+	}
+
+	// the cursor is saved in the state, independently of the logs
+	var state saveState
+	if err := json.Unmarshal(client.GetState(), &state); err != nil {
+		t.Fatal(err)
+	}
+	next, logs := url.URL(state.Cursors["devices"]), url.URL(state.NextPageURL)
+	if next.Query().Get("page") != "2" || logs != pageURL("0") {
+		t.Errorf("saved devices cursor %s and logs cursor %s", next.String(), logs.String())
+	}
+}
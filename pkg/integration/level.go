@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Level is the level of a log, as returned by the Bugfender API.
+// Note enum numbers are not sorted by criticality for backwards compatibility, use Severity to compare levels.
+type Level int
+
+const (
+	LevelDebug   Level = 0
+	LevelWarning Level = 1
+	LevelError   Level = 2
+	LevelTrace   Level = 3
+	LevelInfo    Level = 4
+	LevelFatal   Level = 5
+)
+
+type levelInfo struct {
+	name           string
+	severity       int
+	syslogSeverity int
+}
+
+var levels = map[Level]levelInfo{
+	LevelTrace:   {"trace", 0, 7},
+	LevelDebug:   {"debug", 1, 7},
+	LevelInfo:    {"info", 2, 6},
+	LevelWarning: {"warning", 3, 4},
+	LevelError:   {"error", 4, 3},
+	LevelFatal:   {"fatal", 5, 2},
+}
+
+// syslogSeverityNames are the names of the syslog (RFC 5424) severities
+var syslogSeverityNames = []string{"emergency", "alert", "critical", "error", "warning", "notice", "informational", "debug"}
+
+// ParseLevel returns the level with the given name or number (eg. "error" or "2")
+func ParseLevel(name string) (Level, error) {
+	if n, err := strconv.Atoi(name); err == nil && Level(n).Valid() {
+		return Level(n), nil
+	}
+	for l, info := range levels {
+		if info.name == name {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Valid returns whether the level is one of the known levels
+func (l Level) Valid() bool {
+	_, ok := levels[l]
+	return ok
+}
+
+// String returns the name of the level (eg. "warning")
+func (l Level) String() string {
+	if info, ok := levels[l]; ok {
+		return info.name
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Severity returns a number sorted by criticality, from trace (0) to fatal (5).
+// Unknown levels return -1.
+func (l Level) Severity() int {
+	if info, ok := levels[l]; ok {
+		return info.severity
+	}
+	return -1
+}
+
+// SyslogSeverity returns the equivalent syslog (RFC 5424) severity, from critical (2) to debug (7).
+// Note lower numbers are more critical. Unknown levels return -1.
+func (l Level) SyslogSeverity() int {
+	if info, ok := levels[l]; ok {
+		return info.syslogSeverity
+	}
+	return -1
+}
+
+// SyslogSeverityName returns the name of the equivalent syslog severity (eg. "informational")
+func (l Level) SyslogSeverityName() string {
+	if info, ok := levels[l]; ok {
+		return syslogSeverityNames[info.syslogSeverity]
+	}
+	return ""
+}
+
+// MarshalJSON encodes the level as the number used by the Bugfender API
+func (l Level) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(l))
+}
+
+// UnmarshalJSON decodes the level from either its number or its name
+func (l *Level) UnmarshalJSON(b []byte) error {
+	var n int
+	if json.Unmarshal(b, &n) == nil {
+		*l = Level(n)
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return fmt.Errorf("invalid log level: %s", string(b))
+	}
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
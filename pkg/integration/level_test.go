@@ -0,0 +1,101 @@
+package integration
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		want  Level
+		valid bool
+	}{
+		{"trace", LevelTrace, true},
+		{"debug", LevelDebug, true},
+		{"info", LevelInfo, true},
+		{"warning", LevelWarning, true},
+		{"error", LevelError, true},
+		{"fatal", LevelFatal, true},
+		{"0", LevelDebug, true},
+		{"2", LevelError, true},
+		{"5", LevelFatal, true},
+		{"6", 0, false},
+		{"-1", 0, false},
+		{"Error", 0, false},
+		{"warn", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		got, err := ParseLevel(test.name)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("ParseLevel(%q): got %v, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLevel(%q): %s", test.name, err)
+		} else if got != test.want {
+			t.Errorf("ParseLevel(%q): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	// sorted by criticality, unlike the enum numbers
+	sorted := []Level{LevelTrace, LevelDebug, LevelInfo, LevelWarning, LevelError, LevelFatal}
+	for i, l := range sorted {
+		if got := l.Severity(); got != i {
+			t.Errorf("%s: got severity %d, want %d", l, got, i)
+		}
+		if i > 0 && l.SyslogSeverity() > sorted[i-1].SyslogSeverity() {
+			t.Errorf("%s: got syslog severity %d, less critical than %s", l, l.SyslogSeverity(), sorted[i-1])
+		}
+	}
+	unknown := Level(9)
+	if unknown.Valid() || unknown.Severity() != -1 || unknown.SyslogSeverity() != -1 || unknown.SyslogSeverityName() != "" {
+		t.Errorf("got an unknown level valid, or with severities")
+	}
+	if got, want := unknown.String(), "level(9)"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := LevelInfo.SyslogSeverityName(), "informational"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestLevelJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want Level
+	}{
+		{`2`, LevelError},
+		{`"error"`, LevelError},
+		{`3`, LevelTrace},
+		{`"trace"`, LevelTrace},
+		{`9`, Level(9)}, // unknown numbers are kept, as sent by the API
+	}
+	for _, test := range tests {
+		var got Level
+		if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+			t.Errorf("%s: %s", test.json, err)
+		} else if got != test.want {
+			t.Errorf("%s: got %v, want %v", test.json, got, test.want)
+		}
+	}
+	for _, invalid := range []string{`"nope"`, `true`, `2.5`, `{}`} {
+		var l Level
+		if err := json.Unmarshal([]byte(invalid), &l); err == nil {
+			t.Errorf("%s: got %v, want an error", invalid, l)
+		}
+	}
+	// encoded as the number used by the API
+	b, err := json.Marshal(struct{ Level Level }{LevelWarning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"Level":1}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,6 +1,13 @@
 package integration
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -39,7 +46,7 @@ type Log struct {
 	Line int64 `json:"line"`
 	// Log level: Fatal (5), Error (2), Warning (1), Info (4), Debug (0), Trace (3) (from more to less critical).
 	// Note enum numbers are not sorted for backwards compatibility.
-	Level int `json:"log_level"`
+	Level Level `json:"log_level"`
 	// Log tag
 	Tag string `json:"tag"`
 	// Timestamp when the log was generated (using the originating device's clock).
@@ -97,4 +104,118 @@ type Log struct {
 	InteractionDetail *string `json:"interaction_detail,omitempty"`
 	// JS Element XPath
 	JSXPath *string `json:"js_xpath,omitempty"`
+
+	// Extra contains additional fields that are not defined above:
+	// fields returned by the Bugfender API unknown to this tool, and fields added by processors.
+	// They are written at the top level of the document.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// derivedFields are the names of the fields added when encoding a Log
+var derivedFields = map[string]bool{"log_level_name": true, "severity": true, "syslog_severity": true}
+
+// knownFields are the names of the fields defined in Log
+var knownFields = func() map[string]bool {
+	known := make(map[string]bool)
+	t := reflect.TypeOf(Log{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+	return known
+}()
+
+// IsKnownField returns whether a field name is defined in Log, or derived from its fields
+func IsKnownField(name string) bool {
+	return knownFields[name] || derivedFields[name]
+}
+
+// CheckExtraField returns an error if name can't be the name of a field in Log.Extra, because it is the name of a
+// field defined in Log, which would take precedence
+func CheckExtraField(name string) error {
+	if IsKnownField(name) {
+		return fmt.Errorf("%q is the name of a field of the logs", name)
+	}
+	return nil
+}
+
+// reportedCollisions are the names of the extra fields already reported for colliding with known fields
+var reportedCollisions sync.Map
+
+// ExtraFields returns the extra fields of the log that can be written.
+// Extra fields with the name of a known field are left out, and reported the first time they are seen.
+func (l *Log) ExtraFields() map[string]interface{} {
+	extra := make(map[string]interface{}, len(l.Extra))
+	for k, v := range l.Extra {
+		if !IsKnownField(k) {
+			extra[k] = v
+		} else if _, reported := reportedCollisions.LoadOrStore(k, true); !reported {
+			log.Printf("WARNING: extra field %q is not written, because it is the name of a field of the logs", k)
+		}
+	}
+	return extra
+}
+
+// MarshalJSON encodes the log, adding the fields derived from its level:
+// its name, a severity number sorted by criticality and the equivalent syslog severity
+func (l Log) MarshalJSON() ([]byte, error) {
+	type plainLog Log // same fields, without MarshalJSON
+	doc := struct {
+		plainLog
+		LevelName      string `json:"log_level_name,omitempty"`
+		Severity       *int   `json:"severity,omitempty"`
+		SyslogSeverity *int   `json:"syslog_severity,omitempty"`
+	}{plainLog: plainLog(l)}
+	if l.Level.Valid() {
+		severity, syslogSeverity := l.Level.Severity(), l.Level.SyslogSeverity()
+		doc.LevelName = l.Level.String()
+		doc.Severity = &severity
+		doc.SyslogSeverity = &syslogSeverity
+	}
+	b, err := json.Marshal(doc)
+	if err != nil || len(l.Extra) == 0 {
+		return b, err
+	}
+	// add the extra fields to the encoded object
+	extra := l.ExtraFields()
+	if len(extra) == 0 {
+		return b, nil
+	}
+	e, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Write(b[:len(b)-1]) // without the closing '}'
+	buf.WriteByte(',')
+	buf.Write(e[1:]) // without the opening '{'
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the log, keeping the unknown fields in Extra, decoded like in an interface{}
+// (numbers as json.Number), so that processors can inspect them
+func (l *Log) UnmarshalJSON(b []byte) error {
+	type plainLog Log // same fields, without UnmarshalJSON
+	if err := json.Unmarshal(b, (*plainLog)(l)); err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&fields); err != nil {
+		return err
+	}
+	l.Extra = nil
+	for k, v := range fields {
+		if knownFields[k] {
+			continue
+		}
+		if l.Extra == nil {
+			l.Extra = make(map[string]interface{})
+		}
+		l.Extra[k] = v
+	}
+	return nil
 }
@@ -0,0 +1,9 @@
+package integration
+
+// Mapper converts a log into the document written to a destination
+type Mapper func(Log) interface{}
+
+// DefaultMapper writes logs with the same fields returned by the Bugfender API
+func DefaultMapper(l Log) interface{} {
+	return l
+}
@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// LogProcessor transforms a page of logs before it is written to the destination.
+// Processors can modify the logs in place, and drop or add logs to the page.
+type LogProcessor interface {
+	ProcessLogs(context.Context, []Log) ([]Log, error)
+}
+
+// StatefulProcessor is a LogProcessor whose state is saved in the state file, to resume where it left off after a
+// restart. The state is saved and restored while no logs are being processed.
+type StatefulProcessor interface {
+	LogProcessor
+	// StateKey identifies the state of the processor in the state file
+	StateKey() string
+	// SaveState returns the state of the processor, encoded in JSON
+	SaveState() (json.RawMessage, error)
+	// RestoreState restores a state returned by SaveState
+	RestoreState(json.RawMessage) error
+}
+
+type processingWriter struct {
+	processors  []LogProcessor
+	destination LogWriter
+}
+
+// WithProcessors returns a LogWriter that runs every page through the given processors, in order,
+// before writing it to the destination
+func WithProcessors(destination LogWriter, processors ...LogProcessor) LogWriter {
+	if len(processors) == 0 {
+		return destination
+	}
+	return &processingWriter{
+		processors:  processors,
+		destination: destination,
+	}
+}
+
+// unwrap returns the destination of a LogWriter returned by WithProcessors, or the LogWriter itself
+func unwrap(w LogWriter) LogWriter {
+	if p, ok := w.(*processingWriter); ok {
+		return p.destination
+	}
+	return w
+}
+
+// statefulProcessors returns the processors of a LogWriter returned by WithProcessors that have state
+func statefulProcessors(w LogWriter) []StatefulProcessor {
+	p, ok := w.(*processingWriter)
+	if !ok {
+		return nil
+	}
+	var stateful []StatefulProcessor
+	for _, processor := range p.processors {
+		if sp, ok := processor.(StatefulProcessor); ok {
+			stateful = append(stateful, sp)
+		}
+	}
+	return stateful
+}
+
+func (w *processingWriter) WriteLogs(ctx context.Context, logs []Log) error {
+	logs, err := w.process(ctx, logs)
+	if err != nil {
+		return err
+	}
+	return w.destination.WriteLogs(ctx, logs)
+}
+
+// process runs the logs through the processors
+func (w *processingWriter) process(ctx context.Context, logs []Log) ([]Log, error) {
+	var err error
+	for _, p := range w.processors {
+		logs, err = p.ProcessLogs(ctx, logs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
+}
@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/jsonurl"
+)
+
+// Resource is a Bugfender API resource, other than logs, that can be synchronized.
+// Resources are paginated like logs, at /api/app/{id}/{name}/paginated.
+type Resource struct {
+	// Name identifies the resource in the API and in the state
+	Name string
+	// IDField is the field that identifies each item, used as document ID
+	IDField string
+}
+
+var (
+	// ResourceDevices are the devices of the app
+	ResourceDevices = Resource{Name: "devices", IDField: "udid"}
+	// ResourceCrashes are the crash reports of the app
+	ResourceCrashes = Resource{Name: "crashes", IDField: "uuid"}
+	// ResourceFeedback is the user feedback sent from the app
+	ResourceFeedback = Resource{Name: "feedback", IDField: "uuid"}
+)
+
+// ResourcePage is a page of items of a Resource
+type ResourcePage struct {
+	Resource  Resource
+	Documents []Document
+	next      url.URL
+}
+
+type resourcePage struct {
+	Data        []map[string]interface{} `json:"data"`
+	PreviousURL *jsonurl.URL             `json:"previous"`
+}
+
+// GetNextResourcePage gets the next page of items of the resource, or nil if there is no new page yet.
+// The page is not returned again once it's committed with CommitResourcePage.
+func (dm *Client) GetNextResourcePage(ctx context.Context, r Resource) (*ResourcePage, error) {
+	dm.mu.Lock()
+	cursor, ok := dm.cursors[r.Name]
+	if !ok {
+		cursor = makeFirstResourcePageURL(dm.config, dm.appID, r.Name)
+		dm.cursors[r.Name] = cursor
+	}
+	dm.mu.Unlock()
+	var page resourcePage
+	if err := dm.getPage(ctx, cursor, &page); err != nil {
+		return nil, fmt.Errorf("%s: %s", r.Name, err)
+	}
+	if page.PreviousURL == nil {
+		return nil, nil
+	}
+	docs := make([]Document, 0, len(page.Data))
+	for _, item := range page.Data {
+		var id string
+		if v, ok := item[r.IDField]; ok && v != nil {
+			id = fmt.Sprint(v)
+		}
+		docs = append(docs, Document{ID: id, Body: item})
+	}
+	return &ResourcePage{
+		Resource:  r,
+		Documents: docs,
+		next:      url.URL(*page.PreviousURL),
+	}, nil
+}
+
+// CommitResourcePage marks the page as processed, so that the next page is returned by GetNextResourcePage
+func (dm *Client) CommitResourcePage(page *ResourcePage) {
+	dm.mu.Lock()
+	dm.cursors[page.Resource.Name] = page.next
+	dm.mu.Unlock()
+}
@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+// TransactionalLogWriter is a LogWriter that saves the position of the synchronization in the same transaction
+// as the logs, eg. in a database, so that each log is delivered exactly once even if the tool stops unexpectedly.
+// The position saved in the destination takes precedence over the state file.
+type TransactionalLogWriter interface {
+	LogWriter
+	// WriteLogsWithCursor writes the logs and, atomically, the cursor to resume the synchronization after them,
+	// saved with the given key
+	WriteLogsWithCursor(ctx context.Context, logs []Log, key string, cursor Cursor) error
+	// Cursor returns the cursor saved with the given key, the zero Cursor if none
+	Cursor(ctx context.Context, key string) (Cursor, error)
+}
+
+// Cursor is the position of the synchronization saved in a TransactionalLogWriter
+type Cursor struct {
+	// APIHost is the host of the Bugfender API the logs come from, since the same app ID may exist in another one
+	APIHost string
+	// NextPage is the URL of the next page of logs
+	NextPage string
+}
+
+var _ TransactionalLogWriter = &processingWriter{}
+
+// WriteLogsWithCursor processes the logs and writes them with the cursor, if the destination supports it
+func (w *processingWriter) WriteLogsWithCursor(ctx context.Context, logs []Log, key string, cursor Cursor) error {
+	t, ok := w.destination.(TransactionalLogWriter)
+	if !ok {
+		return w.WriteLogs(ctx, logs)
+	}
+	logs, err := w.process(ctx, logs)
+	if err != nil {
+		return err
+	}
+	return t.WriteLogsWithCursor(ctx, logs, key, cursor)
+}
+
+// Cursor returns the cursor saved in the destination, the zero Cursor if it doesn't save cursors
+func (w *processingWriter) Cursor(ctx context.Context, key string) (Cursor, error) {
+	if t, ok := w.destination.(TransactionalLogWriter); ok {
+		return t.Cursor(ctx, key)
+	}
+	return Cursor{}, nil
+}
+
+// restoreCursor resumes the synchronization from the cursor saved in the destination, if any.
+// Cursors saved for another API host are refused, since the logs of the destination come from another server.
+func (i *Integration) restoreCursor(ctx context.Context) error {
+	key := i.bugfenderClient.logCursorKey()
+	cursor, err := i.transactional.Cursor(ctx, key)
+	if err != nil {
+		return fmt.Errorf("reading the cursor from the destination: %s", err)
+	}
+	if cursor.NextPage == "" {
+		return nil
+	}
+	if host := i.bugfenderClient.apiHost(); cursor.APIHost != host {
+		return fmt.Errorf("the cursor %s saved in the destination is for the API at %q, not %q:"+
+			" use another destination, or delete the cursor to start over", key, cursor.APIHost, host)
+	}
+	next, err := url.Parse(cursor.NextPage)
+	if err != nil {
+		return fmt.Errorf("invalid cursor %s saved in the destination: %s", key, err)
+	}
+	if i.verbose {
+		log.Println("Resuming from the cursor saved in the destination")
+	}
+	i.bugfenderClient.restoreLogCursor(*next)
+	return nil
+}
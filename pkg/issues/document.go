@@ -0,0 +1,78 @@
+package issues
+
+import (
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// maxValues is the maximum number of devices and versions stored in an issue document
+const maxValues = 10000
+
+// maxCountedLogs is the number of UUIDs of the last logs counted stored in an issue document, to count them once.
+// It's the size of the pages of logs, so that pages written again are not counted again.
+const maxCountedLogs = 10000
+
+// upsertScript combines an issue update with the existing issue document
+const upsertScript = `
+def s = ctx._source;
+s.issue_id = params.issue_id;
+s.app = params.app;
+if (s.first_seen == null || params.first_seen.compareTo(s.first_seen) < 0) {
+	s.first_seen = params.first_seen;
+}
+if (s.last_seen == null || params.last_seen.compareTo(s.last_seen) >= 0) {
+	s.last_seen = params.last_seen;
+	s.issue_title = params.issue_title;
+	s.issue_markdown = params.issue_markdown;
+	s.issue_status = params.issue_status;
+}
+if (s.occurrences == null) {
+	s.occurrences = 0;
+}
+if (s.counted_logs == null) {
+	s.counted_logs = [];
+}
+def counted = new HashSet(s.counted_logs);
+for (id in params.logs) {
+	if (counted.add(id)) {
+		s.occurrences += 1;
+		s.counted_logs.add(id);
+	}
+}
+int size = s.counted_logs.size();
+if (size > params.max_counted_logs) {
+	s.counted_logs = new ArrayList(s.counted_logs.subList(size - params.max_counted_logs, size));
+}
+for (field in ['devices', 'versions']) {
+	if (s[field] == null) {
+		s[field] = [];
+	}
+	for (v in params[field]) {
+		if (s[field].size() < params.max_values && !s[field].contains(v)) {
+			s[field].add(v);
+		}
+	}
+}
+s.device_count = s.devices.size();
+`
+
+// Document returns the document that combines the update with the existing issue document, with a script
+func (u *Update) Document() integration.Document {
+	return integration.Document{
+		ID: u.ID,
+		Body: map[string]interface{}{
+			"issue_id":         u.ID,
+			"app":              u.App,
+			"issue_title":      u.Title,
+			"issue_markdown":   u.Markdown,
+			"issue_status":     u.Status,
+			"first_seen":       u.FirstSeen.UTC().Format(integration.ScriptTimeFormat),
+			"last_seen":        u.LastSeen.UTC().Format(integration.ScriptTimeFormat),
+			"logs":             u.Logs,
+			"devices":          u.Devices,
+			"versions":         u.Versions,
+			"max_values":       maxValues,
+			"max_counted_logs": maxCountedLogs,
+		},
+		Script: upsertScript,
+	}
+}
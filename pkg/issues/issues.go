@@ -0,0 +1,111 @@
+package issues
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Update contains the information about an issue found in a page of logs.
+// Writers combine it with the information already stored about the issue.
+type Update struct {
+	ID  string
+	App int64
+	// Title, Markdown and Status are the values in the most recent log
+	Title    *string
+	Markdown *string
+	Status   *int
+	// FirstSeen and LastSeen are the times of the oldest and most recent logs
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Logs are the UUIDs of the logs referencing the issue, so that each log is counted once
+	Logs []string
+	// Devices are the UDIDs of the affected devices
+	Devices []string
+	// Versions are the affected app versions, as "version (build)"
+	Versions []string
+}
+
+// Tracker maintains an issues index from the issues referenced by the logs
+type Tracker struct {
+	writer integration.DocumentWriter
+}
+
+var _ integration.LogProcessor = &Tracker{}
+
+// NewTracker creates a Tracker that writes the issues to writer, which must support scripted documents
+// (see Update.Document)
+func NewTracker(writer integration.DocumentWriter) *Tracker {
+	return &Tracker{writer: writer}
+}
+
+// ProcessLogs writes the issues referenced in the logs, which are not modified
+func (t *Tracker) ProcessLogs(ctx context.Context, logs []integration.Log) ([]integration.Log, error) {
+	updates := Collect(logs)
+	if len(updates) == 0 {
+		return logs, nil
+	}
+	docs := make([]integration.Document, len(updates))
+	for i := range updates {
+		docs[i] = updates[i].Document()
+	}
+	return logs, t.writer.WriteDocuments(ctx, docs)
+}
+
+// Collect returns one Update for each issue referenced in the logs
+func Collect(logs []integration.Log) []Update {
+	type issue struct {
+		update   *Update
+		devices  map[string]bool
+		versions map[string]bool
+	}
+	issues := make(map[string]*issue)
+	var ids []string
+	for i := range logs {
+		l := &logs[i]
+		if l.IssueID == nil || *l.IssueID == "" {
+			continue
+		}
+		is, ok := issues[*l.IssueID]
+		if !ok {
+			is = &issue{
+				update: &Update{
+					ID:        *l.IssueID,
+					App:       l.App,
+					FirstSeen: l.Time,
+					LastSeen:  l.Time,
+				},
+				devices:  make(map[string]bool),
+				versions: make(map[string]bool),
+			}
+			issues[*l.IssueID] = is
+			ids = append(ids, *l.IssueID)
+		}
+		u := is.update
+		u.Logs = append(u.Logs, l.Uuid.String())
+		if l.Time.Before(u.FirstSeen) {
+			u.FirstSeen = l.Time
+		}
+		if !l.Time.Before(u.LastSeen) {
+			u.LastSeen = l.Time
+			u.Title, u.Markdown, u.Status = l.IssueTitle, l.IssueMarkdown, l.IssueStatus
+		}
+		if !is.devices[l.DeviceUDID] {
+			is.devices[l.DeviceUDID] = true
+			u.Devices = append(u.Devices, l.DeviceUDID)
+		}
+		version := l.VersionVersion + " (" + l.VersionBuild + ")"
+		if !is.versions[version] {
+			is.versions[version] = true
+			u.Versions = append(u.Versions, version)
+		}
+	}
+	sort.Strings(ids)
+	updates := make([]Update, 0, len(ids))
+	for _, id := range ids {
+		updates = append(updates, *issues[id].update)
+	}
+	return updates
+}
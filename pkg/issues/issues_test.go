@@ -0,0 +1,139 @@
+package issues
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func str(s string) *string { return &s }
+
+func intPtr(i int) *int { return &i }
+
+func issueLog(id string, t time.Time, title, device, version string) integration.Log {
+	return integration.Log{
+		Uuid:           uuid.NewV5(uuid.NamespaceOID, id+t.String()+device),
+		App:            1,
+		Time:           t,
+		IssueID:        str(id),
+		IssueTitle:     str(title),
+		IssueMarkdown:  str("# " + title),
+		IssueStatus:    intPtr(len(title)),
+		DeviceUDID:     device,
+		VersionVersion: version,
+		VersionBuild:   "1",
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := []integration.Log{
+		issueLog("b", t0.Add(2*time.Minute), "renamed", "d1", "1.0"),
+		issueLog("b", t0, "original", "d2", "1.0"),
+		{Text: "no issue", Time: t0},
+		issueLog("a", t0.Add(time.Minute), "other", "d1", "2.0"),
+		issueLog("b", t0.Add(time.Minute), "in between", "d1", "1.1"),
+		{Text: "empty issue", IssueID: str("")},
+	}
+	updates := Collect(logs)
+	if len(updates) != 2 || updates[0].ID != "a" || updates[1].ID != "b" {
+		t.Fatalf("got updates %+v, want issues a and b", updates)
+	}
+	b := updates[1]
+	if !b.FirstSeen.Equal(t0) || !b.LastSeen.Equal(t0.Add(2*time.Minute)) {
+		t.Errorf("first and last seen %s and %s, want %s and %s", b.FirstSeen, b.LastSeen, t0, t0.Add(2*time.Minute))
+	}
+	// the values of the most recent log win, even if it's not the last one
+	if *b.Title != "renamed" || *b.Markdown != "# renamed" || *b.Status != len("renamed") {
+		t.Errorf("got title %s, markdown %s, status %d, want the ones of the latest log", *b.Title, *b.Markdown, *b.Status)
+	}
+	if want := []string{"d1", "d2"}; !reflect.DeepEqual(b.Devices, want) {
+		t.Errorf("devices %v, want %v", b.Devices, want)
+	}
+	if want := []string{"1.0 (1)", "1.1 (1)"}; !reflect.DeepEqual(b.Versions, want) {
+		t.Errorf("versions %v, want %v", b.Versions, want)
+	}
+	if want := []string{logs[0].Uuid.String(), logs[1].Uuid.String(), logs[4].Uuid.String()}; !reflect.DeepEqual(b.Logs, want) {
+		t.Errorf("logs %v, want %v", b.Logs, want)
+	}
+}
+
+func TestCollectTies(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// with the same time, the later log in the page wins
+	updates := Collect([]integration.Log{issueLog("a", t0, "first", "d1", "1.0"), issueLog("a", t0, "second", "d1", "1.0")})
+	if len(updates) != 1 || *updates[0].Title != "second" {
+		t.Errorf("got %+v, want the title of the second log", updates)
+	}
+	if len(updates[0].Devices) != 1 || len(updates[0].Versions) != 1 {
+		t.Errorf("got devices %v and versions %v, want them once", updates[0].Devices, updates[0].Versions)
+	}
+}
+
+var paramRegexp = regexp.MustCompile(`params\.(\w+)`)
+
+func TestDocumentParams(t *testing.T) {
+	u := Collect([]integration.Log{issueLog("a", time.Now(), "title", "d1", "1.0")})[0]
+	doc := u.Document()
+	if doc.ID != "a" || doc.Script != upsertScript {
+		t.Fatalf("got document %s with script %q", doc.ID, doc.Script)
+	}
+	params := doc.Body.(map[string]interface{})
+	// the params used in the script, including params[field] for devices and versions
+	used := map[string]bool{"devices": true, "versions": true}
+	for _, m := range paramRegexp.FindAllStringSubmatch(upsertScript, -1) {
+		used[m[1]] = true
+	}
+	var missing, unused []string
+	for name := range used {
+		if _, ok := params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	for name := range params {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unused)
+	if len(missing) > 0 || len(unused) > 0 {
+		t.Errorf("params missing: %v, not used by the script: %v", missing, unused)
+	}
+	// times are compared as strings in the script, so they must sort chronologically
+	if first := params["first_seen"].(string); len(first) != len(integration.ScriptTimeFormat) {
+		t.Errorf("first_seen %s, want the format %s", first, integration.ScriptTimeFormat)
+	}
+}
+
+// documents records the documents written
+type documents []integration.Document
+
+func (d *documents) WriteDocuments(_ context.Context, docs []integration.Document) error {
+	*d = append(*d, docs...)
+	return nil
+}
+
+func TestProcessLogs(t *testing.T) {
+	var written documents
+	tracker := NewTracker(&written)
+	logs := []integration.Log{issueLog("a", time.Now(), "title", "d1", "1.0"), {Text: "no issue"}}
+	got, err := tracker.ProcessLogs(context.Background(), logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || len(written) != 1 || written[0].ID != "a" {
+		t.Errorf("got %d logs and documents %v, want 2 logs and the document of a", len(got), written)
+	}
+	// pages without issues write nothing
+	if _, err := tracker.ProcessLogs(context.Background(), logs[1:]); err != nil || len(written) != 1 {
+		t.Errorf("got %d documents, error %v, want no more", len(written), err)
+	}
+}
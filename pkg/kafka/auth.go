@@ -0,0 +1,117 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg/scram"
+)
+
+// TLSConfig contains the TLS parameters to connect to the brokers
+type TLSConfig struct {
+	Enabled bool
+	// CAFile is a PEM file with the certificate authorities to trust (default: the system ones)
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key, for mutual TLS (default: none)
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify skips the verification of the broker certificates (insecure)
+	InsecureSkipVerify bool
+}
+
+func (config *TLSConfig) apply(sc *sarama.Config) error {
+	if !config.Enabled {
+		return nil
+	}
+	// #nosec G402 InsecureSkipVerify is only set if the user asks for it
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	sc.Net.TLS.Enable = true
+	sc.Net.TLS.Config = tlsConfig
+	return nil
+}
+
+// SASLConfig contains the SASL parameters to authenticate with the brokers
+type SASLConfig struct {
+	// Mechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512 (default: no SASL)
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+func (config *SASLConfig) apply(sc *sarama.Config) error {
+	switch config.Mechanism {
+	case "":
+		return nil
+	case sarama.SASLTypePlaintext:
+	case sarama.SASLTypeSCRAMSHA256:
+		sc.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{HashGeneratorFcn: scramSHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		sc.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{HashGeneratorFcn: scramSHA512}
+		}
+	default:
+		return fmt.Errorf("invalid SASL mechanism %s, expected %s, %s or %s", config.Mechanism,
+			sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512)
+	}
+	sc.Net.SASL.Enable = true
+	sc.Net.SASL.Mechanism = sarama.SASLMechanism(config.Mechanism)
+	sc.Net.SASL.User = config.Username
+	sc.Net.SASL.Password = config.Password
+	return nil
+}
+
+// scramClient implements sarama.SCRAMClient
+type scramClient struct {
+	*scram.Client
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+var _ sarama.SCRAMClient = &scramClient{}
+
+var (
+	scramSHA256 scram.HashGeneratorFcn = sha256.New
+	scramSHA512 scram.HashGeneratorFcn = sha512.New
+)
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	var err error
+	c.Client, err = c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.ClientConversation = c.Client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.ClientConversation.Done()
+}
@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+const (
+	// KeyDevice partitions logs by device, which keeps the order of the logs of each device
+	KeyDevice = "device_udid"
+	// KeyUUID partitions logs by their UUID, which spreads them evenly
+	KeyUUID = "uuid"
+	// KeyNone sends logs without key, to random partitions
+	KeyNone = "none"
+)
+
+// Client produces logs to a Kafka topic
+type Client struct {
+	producer sarama.SyncProducer
+	topic    string
+	key      string
+	mapper   integration.Mapper
+}
+
+// Config contains the parameters to produce logs to Kafka
+type Config struct {
+	Brokers []string
+	// Topic to produce logs to, {app} is replaced by the app ID (eg. bugfender-{app})
+	Topic string
+	// Key is the partitioning key: KeyDevice (default), KeyUUID or KeyNone
+	Key string
+	// Version is the Kafka version of the brokers (default: 2.1.0)
+	Version string
+	// Compression is none (default), gzip, snappy, lz4 or zstd
+	Compression string
+	// TLS connects to the brokers with TLS
+	TLS TLSConfig
+	// SASL authenticates with SASL, if SASL.Mechanism is not empty
+	SASL SASLConfig
+	// Mapper converts logs to the JSON documents produced (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates a Kafka client with an idempotent producer that waits for the acknowledgement of all in-sync replicas.
+// It is compulsory to call Close when done.
+func NewClient(config Config) (*Client, error) {
+	kc := &Client{
+		topic:  config.Topic,
+		key:    config.Key,
+		mapper: config.Mapper,
+	}
+	if kc.topic == "" {
+		return nil, fmt.Errorf("a topic is needed")
+	}
+	switch kc.key {
+	case "":
+		kc.key = KeyDevice
+	case KeyDevice, KeyUUID, KeyNone:
+	default:
+		return nil, fmt.Errorf("invalid key %s, expected %s, %s or %s", kc.key, KeyDevice, KeyUUID, KeyNone)
+	}
+	if kc.mapper == nil {
+		kc.mapper = integration.DefaultMapper
+	}
+
+	sc := sarama.NewConfig()
+	sc.ClientID = "bugfender-integration"
+	sc.Version = sarama.V2_1_0_0
+	if config.Version != "" {
+		version, err := sarama.ParseKafkaVersion(config.Version)
+		if err != nil {
+			return nil, err
+		}
+		sc.Version = version
+	}
+	// exactly once per partition, and only acknowledged when all in-sync replicas have the logs
+	sc.Producer.Idempotent = true
+	sc.Producer.RequiredAcks = sarama.WaitForAll
+	sc.Net.MaxOpenRequests = 1
+	sc.Producer.Retry.Max = 5
+	sc.Producer.Return.Successes = true
+	sc.Producer.Return.Errors = true
+	switch config.Compression {
+	case "", "none":
+		sc.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		sc.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		sc.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		sc.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		sc.Producer.Compression = sarama.CompressionZSTD
+	default:
+		return nil, fmt.Errorf("invalid compression %s, expected none, gzip, snappy, lz4 or zstd", config.Compression)
+	}
+	if err := config.TLS.apply(sc); err != nil {
+		return nil, err
+	}
+	if err := config.SASL.apply(sc); err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducer(config.Brokers, sc)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to Kafka: %s", err)
+	}
+	kc.producer = producer
+	return kc, nil
+}
+
+// WriteLogs produces the logs to Kafka, and waits until they are acknowledged,
+// so that the synchronization only moves on when they are safely stored
+func (kc *Client) WriteLogs(_ context.Context, logs []integration.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(logs))
+	for i := range logs {
+		l := &logs[i]
+		value, err := json.Marshal(kc.mapper(*l))
+		if err != nil {
+			panic(err) // programming error
+		}
+		msg := &sarama.ProducerMessage{
+			Topic:     strings.Replace(kc.topic, "{app}", strconv.FormatInt(l.App, 10), -1),
+			Value:     sarama.ByteEncoder(value),
+			Timestamp: l.Time,
+		}
+		switch kc.key {
+		case KeyDevice:
+			msg.Key = sarama.StringEncoder(l.DeviceUDID)
+		case KeyUUID:
+			msg.Key = sarama.StringEncoder(l.Uuid.String())
+		}
+		msgs = append(msgs, msg)
+	}
+	err := kc.producer.SendMessages(msgs)
+	if errs, ok := err.(sarama.ProducerErrors); ok && len(errs) > 0 {
+		return fmt.Errorf("producing to Kafka: %d of %d logs failed, first error: %s", len(errs), len(msgs), errs[0].Err)
+	}
+	return err
+}
+
+// Close waits for pending logs and closes the connections
+func (kc *Client) Close(_ context.Context) error {
+	return kc.producer.Close()
+}
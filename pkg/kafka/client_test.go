@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func testLogs() []integration.Log {
+	var logs []integration.Log
+	for _, device := range []string{"a", "b", "a", "c", "a"} {
+		logs = append(logs, integration.Log{Uuid: uuid.Must(uuid.NewV4()), App: 1, DeviceUDID: device, Text: "log"})
+	}
+	return logs
+}
+
+func TestPartitioning(t *testing.T) {
+	for _, key := range []string{KeyDevice, KeyUUID, KeyNone} {
+		t.Run(key, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			kc := &Client{producer: producer, topic: "bugfender-{app}", key: key, mapper: integration.DefaultMapper}
+			logs := testLogs()
+			partitions := make(map[string]int32) // by device
+			for i := range logs {
+				l := &logs[i]
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					if msg.Topic != "bugfender-1" {
+						return fmt.Errorf("topic %s, want bugfender-1", msg.Topic)
+					}
+					var want sarama.Encoder
+					switch key {
+					case KeyDevice:
+						want = sarama.StringEncoder(l.DeviceUDID)
+						if p, ok := partitions[l.DeviceUDID]; ok && p != msg.Partition {
+							return fmt.Errorf("device %s produced to partitions %d and %d", l.DeviceUDID, p, msg.Partition)
+						}
+						partitions[l.DeviceUDID] = msg.Partition
+					case KeyUUID:
+						want = sarama.StringEncoder(l.Uuid.String())
+					}
+					if msg.Key != want {
+						return fmt.Errorf("key %v, want %v", msg.Key, want)
+					}
+					return nil
+				})
+			}
+			if err := kc.WriteLogs(context.Background(), logs); err != nil {
+				t.Fatal(err)
+			}
+			if err := kc.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestIdempotentProducerErrors(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("logs", 0, broker.BrokerID()),
+		"InitProducerIDRequest": sarama.NewMockWrapper(&sarama.InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 1}),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3).
+			SetError("logs", 0, sarama.ErrOutOfOrderSequenceNumber),
+	})
+
+	kc, err := NewClient(Config{Brokers: []string{broker.Addr()}, Topic: "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = kc.Close(context.Background()) }()
+	// the logs are not acknowledged, so the error is returned for the page to be retried
+	err = kc.WriteLogs(context.Background(), testLogs())
+	want := "5 of 5 logs failed, first error: " + sarama.ErrOutOfOrderSequenceNumber.Error()
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,212 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+const (
+	// FormatProtobuf pushes logs in protobuf, compressed with snappy (the most efficient)
+	FormatProtobuf = "protobuf"
+	// FormatJSON pushes logs in JSON
+	FormatJSON = "json"
+)
+
+// pushPath is the path of the push API, relative to the Loki URL
+const pushPath = "/loki/api/v1/push"
+
+// maxBatchBytes is the maximum size of the log lines pushed in one request, well below the Loki default limit (4 MB)
+const maxBatchBytes = 1 << 20
+
+// Client pushes logs to Grafana Loki
+type Client struct {
+	url          string
+	tenantID     string
+	username     string
+	password     string
+	labelNames   []string
+	staticLabels map[string]string
+	format       string
+	batchSize    int
+	skipRejected bool
+	rejected     int64 // number of logs skipped, accessed atomically
+	mapper       integration.Mapper
+	httpClient   *http.Client
+}
+
+// Config contains the parameters to push logs to Loki
+type Config struct {
+	// URL is the base URL of Loki, eg. http://localhost:3100
+	URL string
+	// TenantID is sent in the X-Scope-OrgID header, in multi-tenant installations (default: none)
+	TenantID string
+	Username string
+	Password string
+	// Labels are the names of the labels extracted from each log (default: DefaultLabels)
+	Labels []string
+	// StaticLabels are added to all logs (eg. job=bugfender)
+	StaticLabels map[string]string
+	// Format is FormatProtobuf (default) or FormatJSON
+	Format string
+	// BatchSize is the maximum number of logs pushed in one request (default: 1000)
+	BatchSize int
+	// SkipRejected skips the batches rejected by Loki (eg. because they are too old), instead of returning an error
+	// and pushing them again until they are accepted
+	SkipRejected bool
+	// Mapper converts logs to the JSON documents used as log lines (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+	// Timeout of each request (default: 30s)
+	Timeout time.Duration
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates a Loki client with the given parameters
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		url:          strings.TrimSuffix(config.URL, "/") + pushPath,
+		tenantID:     config.TenantID,
+		username:     config.Username,
+		password:     config.Password,
+		labelNames:   config.Labels,
+		staticLabels: config.StaticLabels,
+		format:       config.Format,
+		batchSize:    config.BatchSize,
+		skipRejected: config.SkipRejected,
+		mapper:       config.Mapper,
+		httpClient:   &http.Client{Timeout: config.Timeout},
+	}
+	if c.labelNames == nil {
+		c.labelNames = DefaultLabels
+	}
+	for _, name := range c.labelNames {
+		if _, ok := labelFuncs[name]; !ok {
+			return nil, fmt.Errorf("unknown label %s, valid labels: %s", name, strings.Join(LabelNames(), " "))
+		}
+		if _, ok := c.staticLabels[name]; ok {
+			return nil, fmt.Errorf("label %s is both static and extracted from logs", name)
+		}
+	}
+	if len(c.labelNames) == 0 && len(c.staticLabels) == 0 {
+		return nil, fmt.Errorf("at least one label is needed")
+	}
+	switch c.format {
+	case "":
+		c.format = FormatProtobuf
+	case FormatProtobuf, FormatJSON:
+	default:
+		return nil, fmt.Errorf("invalid format %s, expected %s or %s", c.format, FormatProtobuf, FormatJSON)
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 1000
+	}
+	if c.mapper == nil {
+		c.mapper = integration.DefaultMapper
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = 30 * time.Second
+	}
+	return c, nil
+}
+
+// WriteLogs pushes logs to Loki, in batches.
+// Loki requires the entries of each stream to be in order, so logs are sorted by Time and AbsoluteTime.
+// Pushing the same logs again (eg. after a failure) doesn't duplicate them, because Loki ignores entries
+// with the same labels, timestamp and line.
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	sorted := make([]*integration.Log, 0, len(logs))
+	for i := range logs {
+		sorted = append(sorted, &logs[i])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].Time.Before(sorted[j].Time)
+		}
+		return sorted[i].AbsoluteTime < sorted[j].AbsoluteTime
+	})
+	var batch []stream
+	streams := make(map[string]int) // index of each stream in the batch, by labels
+	count, size := 0, 0
+	for _, l := range sorted {
+		line, err := json.Marshal(c.mapper(*l))
+		if err != nil {
+			panic(err) // programming error
+		}
+		if count == c.batchSize || (count > 0 && size+len(line) > maxBatchBytes) {
+			if err := c.push(ctx, batch, count); err != nil {
+				return err
+			}
+			batch, streams, count, size = nil, make(map[string]int), 0, 0
+		}
+		labels := c.labels(l)
+		key := labels.String()
+		i, ok := streams[key]
+		if !ok {
+			i = len(batch)
+			streams[key] = i
+			batch = append(batch, stream{labels: labels})
+		}
+		batch[i].entries = append(batch[i].entries, entry{time: l.Time, line: string(line)})
+		count++
+		size += len(line)
+	}
+	if count > 0 {
+		return c.push(ctx, batch, count)
+	}
+	return nil
+}
+
+// push sends a batch of streams, with count logs, to Loki.
+// With SkipRejected, rejected logs (eg. too old) are logged and counted; otherwise all errors are returned.
+func (c *Client) push(ctx context.Context, streams []stream, count int) error {
+	var body []byte
+	var contentType string
+	if c.format == FormatJSON {
+		var err error
+		if body, err = encodeJSON(streams); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = encodeProtobuf(streams)
+		contentType = "application/x-protobuf"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.tenantID)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing logs to Loki: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(res.Body)
+	err = fmt.Errorf("pushing logs to Loki: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	if res.StatusCode == http.StatusBadRequest && c.skipRejected {
+		total := atomic.AddInt64(&c.rejected, int64(count))
+		log.Printf("ERROR: skipping %d logs (%d so far): %s", count, total, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package loki
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// stream is a sequence of log lines with the same labels
+type stream struct {
+	labels  labelSet
+	entries []entry
+}
+
+type entry struct {
+	time time.Time
+	line string
+}
+
+// encodeProtobuf encodes a push request like logproto.PushRequest, compressed with snappy:
+//
+//	PushRequest   { repeated StreamAdapter streams = 1; }
+//	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter  { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func encodeProtobuf(streams []stream) []byte {
+	var req []byte
+	for _, s := range streams {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.BytesType)
+		sb = protowire.AppendString(sb, s.labels.String())
+		for _, e := range s.entries {
+			var ts []byte
+			ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.time.Unix()))
+			ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.time.Nanosecond()))
+			var eb []byte
+			eb = protowire.AppendTag(eb, 1, protowire.BytesType)
+			eb = protowire.AppendBytes(eb, ts)
+			eb = protowire.AppendTag(eb, 2, protowire.BytesType)
+			eb = protowire.AppendString(eb, e.line)
+			sb = protowire.AppendTag(sb, 2, protowire.BytesType)
+			sb = protowire.AppendBytes(sb, eb)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, sb)
+	}
+	return snappy.Encode(nil, req)
+}
+
+type jsonPushRequest struct {
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// encodeJSON encodes a push request in JSON: {"streams": [{"stream": {labels}, "values": [["unix ns", "line"]]}]}
+func encodeJSON(streams []stream) ([]byte, error) {
+	req := jsonPushRequest{Streams: make([]jsonStream, 0, len(streams))}
+	for _, s := range streams {
+		js := jsonStream{Stream: s.labels.Map(), Values: make([][2]string, 0, len(s.entries))}
+		for _, e := range s.entries {
+			js.Values = append(js.Values, [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+		}
+		req.Streams = append(req.Streams, js)
+	}
+	return json.Marshal(req)
+}
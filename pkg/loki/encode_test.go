@@ -0,0 +1,118 @@
+package loki
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var testStreams = []stream{
+	{labels: labelSet{{"app", "1"}, {"level", "info"}}, entries: []entry{
+		{time.Unix(1700000000, 123456789), `{"text":"first"}`},
+		{time.Unix(1700000001, 0), `{"text":"second"}`},
+	}},
+	{labels: labelSet{{"app", "2"}}, entries: []entry{{time.Unix(1700000002, 5), `{"text":"third"}`}}},
+}
+
+// consume decodes the fields of a protobuf message, failing on wire types other than varint and bytes
+func consume(t *testing.T, b []byte, f func(num protowire.Number, v uint64, b []byte)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			f(num, v, nil)
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			f(num, 0, v)
+			b = b[n:]
+		default:
+			t.Fatalf("field %d: unexpected wire type %d", num, typ)
+		}
+	}
+}
+
+func TestEncodeProtobuf(t *testing.T) {
+	req, err := snappy.Decode(nil, encodeProtobuf(testStreams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// decode the streams as labels -> "seconds.nanos line"
+	var got [][]string
+	consume(t, req, func(num protowire.Number, _ uint64, b []byte) {
+		if num != 1 { // PushRequest.streams
+			t.Fatalf("unexpected PushRequest field %d", num)
+		}
+		var s []string
+		consume(t, b, func(num protowire.Number, _ uint64, b []byte) {
+			switch num {
+			case 1: // StreamAdapter.labels
+				s = append(s, string(b))
+			case 2: // StreamAdapter.entries
+				var seconds, nanos uint64
+				var line string
+				consume(t, b, func(num protowire.Number, _ uint64, b []byte) {
+					switch num {
+					case 1: // EntryAdapter.timestamp
+						consume(t, b, func(num protowire.Number, v uint64, _ []byte) {
+							if num == 1 {
+								seconds = v
+							} else if num == 2 {
+								nanos = v
+							}
+						})
+					case 2: // EntryAdapter.line
+						line = string(b)
+					default:
+						t.Fatalf("unexpected EntryAdapter field %d", num)
+					}
+				})
+				s = append(s, time.Unix(int64(seconds), int64(nanos)).UTC().Format(time.RFC3339Nano)+" "+line)
+			default:
+				t.Fatalf("unexpected StreamAdapter field %d", num)
+			}
+		})
+		got = append(got, s)
+	})
+	want := [][]string{
+		{`{app="1", level="info"}`, `2023-11-14T22:13:20.123456789Z {"text":"first"}`,
+			`2023-11-14T22:13:21Z {"text":"second"}`},
+		{`{app="2"}`, `2023-11-14T22:13:22.000000005Z {"text":"third"}`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	b, err := encodeJSON(testStreams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"streams":[` +
+		`{"stream":{"app":"1","level":"info"},"values":[["1700000000123456789","{\"text\":\"first\"}"],` +
+		`["1700000001000000000","{\"text\":\"second\"}"]]},` +
+		`{"stream":{"app":"2"},"values":[["1700000002000000005","{\"text\":\"third\"}"]]}]}`
+	if string(b) != want {
+		t.Errorf("got %s\nwant %s", b, want)
+	}
+	if !json.Valid(b) {
+		t.Error("invalid JSON")
+	}
+}
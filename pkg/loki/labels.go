@@ -0,0 +1,107 @@
+package loki
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// DefaultLabels are the labels extracted from logs by default.
+// Every combination of label values is a different stream in Loki, so labels must have few distinct values.
+var DefaultLabels = []string{"app", "level"}
+
+// labelFuncs return the value of each label that can be extracted from a log
+var labelFuncs = map[string]func(l *integration.Log) string{
+	"app":         func(l *integration.Log) string { return strconv.FormatInt(l.App, 10) },
+	"level":       func(l *integration.Log) string { return l.Level.String() },
+	"device_type": func(l *integration.Log) string { return l.DeviceType },
+	"version":     func(l *integration.Log) string { return l.VersionVersion },
+	"build":       func(l *integration.Log) string { return l.VersionBuild },
+	"os_version":  func(l *integration.Log) string { return l.OSVersion },
+	"language":    func(l *integration.Log) string { return l.Language },
+	"tag":         func(l *integration.Log) string { return l.Tag },
+	"type":        func(l *integration.Log) string { return l.Type },
+}
+
+// LabelNames returns the names of the labels that can be extracted from logs, sorted
+func LabelNames() []string {
+	names := make([]string, 0, len(labelFuncs))
+	for name := range labelFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ParseStaticLabels parses a list of labels added to all logs, separated by spaces: name=value
+func ParseStaticLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Fields(s) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || !labelNameRegexp.MatchString(kv[0]) || kv[1] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected name=value", pair)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+// labelSet is a set of labels identifying a stream
+type labelSet []labelPair
+
+type labelPair struct {
+	name, value string
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// String returns the labels in the Prometheus format used by the protobuf API: {a="1", b="2"}
+func (ls labelSet) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, p := range ls {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(p.name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(p.value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Map returns the labels as a map, as used by the JSON API
+func (ls labelSet) Map() map[string]string {
+	m := make(map[string]string, len(ls))
+	for _, p := range ls {
+		m[p.name] = p.value
+	}
+	return m
+}
+
+// labels returns the labels of a log, sorted by name. Labels with empty values are omitted, and if none is left the
+// app label is used, because Loki rejects streams without labels.
+func (c *Client) labels(l *integration.Log) labelSet {
+	ls := make(labelSet, 0, len(c.labelNames)+len(c.staticLabels))
+	for name, value := range c.staticLabels {
+		ls = append(ls, labelPair{name, value})
+	}
+	for _, name := range c.labelNames {
+		if value := labelFuncs[name](l); value != "" {
+			ls = append(ls, labelPair{name, value})
+		}
+	}
+	if len(ls) == 0 {
+		ls = append(ls, labelPair{"app", labelFuncs["app"](l)})
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].name < ls[j].name })
+	return ls
+}
@@ -0,0 +1,69 @@
+package loki
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func TestLabels(t *testing.T) {
+	l := &integration.Log{App: 42, Level: integration.LevelWarning, DeviceType: "iPhone13,2"}
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"default", Config{}, `{app="42", level="warning"}`},
+		{"sorted with static labels", Config{Labels: []string{"level", "device_type"},
+			StaticLabels: map[string]string{"job": "bugfender"}},
+			`{device_type="iPhone13,2", job="bugfender", level="warning"}`},
+		{"empty values omitted", Config{Labels: []string{"level", "version"}}, `{level="warning"}`},
+		{"app when no label is left", Config{Labels: []string{"version", "tag"}}, `{app="42"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.labels(l).String(); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLabelSet(t *testing.T) {
+	ls := labelSet{{"a", `quote " backslash \ newline` + "\n"}, {"b", "2"}}
+	if want := `{a="quote \" backslash \\ newline\n", b="2"}`; ls.String() != want {
+		t.Errorf("got %s, want %s", ls.String(), want)
+	}
+	if want := map[string]string{"a": `quote " backslash \ newline` + "\n", "b": "2"}; !reflect.DeepEqual(ls.Map(), want) {
+		t.Errorf("got %v, want %v", ls.Map(), want)
+	}
+}
+
+func TestParseStaticLabels(t *testing.T) {
+	labels, err := ParseStaticLabels("job=bugfender  env=prod")
+	if want := map[string]string{"job": "bugfender", "env": "prod"}; err != nil || !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, %v, want %v", labels, err, want)
+	}
+	for _, s := range []string{"job", "job=", "1job=x", "job-name=x"} {
+		if _, err := ParseStaticLabels(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestNewClientLabels(t *testing.T) {
+	for _, config := range []Config{
+		{Labels: []string{"device_udid"}},                                      // unknown
+		{Labels: []string{"app"}, StaticLabels: map[string]string{"app": "x"}}, // both
+		{Labels: []string{}}, // none
+	} {
+		if _, err := NewClient(config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+	}
+}
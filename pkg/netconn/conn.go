@@ -0,0 +1,159 @@
+package netconn
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn is a connection to a server that only receives data, eg. a syslog server, established when writing and
+// established again when it's broken. It can be used from several goroutines.
+type Conn struct {
+	name      string
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	mu        sync.Mutex // protects conn and raw
+	conn      net.Conn   // nil when not connected
+	raw       net.Conn   // TCP connection of conn, to check it without TLS
+}
+
+// Config contains the parameters of the connection
+type Config struct {
+	// Name of the server in the messages logged, eg. syslog
+	Name string
+	// Network is udp or tcp
+	Network string
+	// Address of the server, eg. localhost:514
+	Address string
+	// TLS connects with TLS over TCP, if not nil
+	TLS *tls.Config
+	// Timeout to connect and to write (default: 30s)
+	Timeout time.Duration
+}
+
+// New creates a connection with the given parameters. It is compulsory to call Close when done.
+func New(config Config) *Conn {
+	c := &Conn{
+		name:      config.Name,
+		network:   config.Network,
+		address:   config.Address,
+		tlsConfig: config.TLS,
+		timeout:   config.Timeout,
+	}
+	if c.timeout == 0 {
+		c.timeout = 30 * time.Second
+	}
+	return c
+}
+
+// Write calls write to write to the connection, connecting first if needed.
+// Over TCP, if the connection is broken, it reconnects and calls write again once; other errors close the connection,
+// to reconnect in the next call.
+// Note a TCP connection closed by the server is only detected when writing to it fails, so the data written
+// just before may be lost. The connection is checked before writing, to make it unlikely.
+func (c *Conn) Write(ctx context.Context, write func(io.Writer) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reused := c.conn != nil && c.alive()
+	if c.conn != nil && !reused {
+		c.disconnect()
+		log.Printf("Reconnecting to %s: the connection was closed", c.name)
+	}
+	err := c.send(ctx, write)
+	if err != nil && reused && c.network != "udp" {
+		c.disconnect()
+		log.Printf("Reconnecting to %s: %s", c.name, err)
+		err = c.send(ctx, write)
+	}
+	if err != nil {
+		c.disconnect()
+	}
+	return err
+}
+
+// send connects if needed and calls write with the write deadline set
+func (c *Conn) send(ctx context.Context, write func(io.Writer) error) error {
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+	}
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	return write(c.conn)
+}
+
+// connect connects to the server
+func (c *Conn) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	raw, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return err
+	}
+	conn := raw
+	if c.tlsConfig != nil {
+		tlsConfig := c.tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(c.address)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := raw.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			_ = raw.Close()
+			return err
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			_ = raw.Close()
+			return err
+		}
+		if err := raw.SetDeadline(time.Time{}); err != nil {
+			_ = raw.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+	c.conn, c.raw = conn, raw
+	return nil
+}
+
+// alive returns whether the connection is still open, reading from it for a moment.
+// The servers don't send anything, so receiving anything means the connection was closed.
+func (c *Conn) alive() bool {
+	if c.network == "udp" {
+		return true
+	}
+	// a deadline in the past would fail without reading
+	if err := c.raw.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+	defer func() { _ = c.raw.SetReadDeadline(time.Time{}) }()
+	_, err := c.raw.Read(make([]byte, 1))
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// disconnect closes the connection, if any
+func (c *Conn) disconnect() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn, c.raw = nil, nil
+	}
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disconnect()
+	return nil
+}
@@ -0,0 +1,141 @@
+package netconn
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// server accepts TCP connections and sends the lines received on each one
+func server(t *testing.T) (net.Listener, chan net.Conn, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 10)
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+	return l, conns, lines
+}
+
+func writeLine(line string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, line+"\n")
+		return err
+	}
+}
+
+func receive(t *testing.T, lines chan string, want string) {
+	select {
+	case line := <-lines:
+		if line != want {
+			t.Errorf("received %q, want %q", line, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%q not received", want)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	l, conns, lines := server(t)
+	defer func() { _ = l.Close() }()
+	c := New(Config{Name: "test", Network: "tcp", Address: l.Addr().String(), Timeout: 5 * time.Second})
+	defer func() { _ = c.Close() }()
+	ctx := context.Background()
+
+	if err := c.Write(ctx, writeLine("first")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, lines, "first")
+	first := <-conns
+
+	// the connection is reused
+	if err := c.Write(ctx, writeLine("second")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, lines, "second")
+
+	// a connection closed by the server is established again
+	_ = first.Close()
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Write(ctx, writeLine("third")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, lines, "third")
+	select {
+	case <-conns:
+	default:
+		t.Error("no new connection")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	l, conns, lines := server(t)
+	defer func() { _ = l.Close() }()
+	c := New(Config{Name: "test", Network: "tcp", Address: l.Addr().String(), Timeout: 5 * time.Second})
+	defer func() { _ = c.Close() }()
+	ctx := context.Background()
+
+	if err := c.Write(ctx, writeLine("first")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, lines, "first")
+	<-conns
+
+	// errors of reused connections are retried once, with a new connection
+	calls := 0
+	err := c.Write(ctx, func(w io.Writer) error {
+		calls++
+		return io.ErrShortWrite
+	})
+	if err != io.ErrShortWrite || calls != 2 {
+		t.Errorf("got %v after %d calls, want %v after 2", err, calls, io.ErrShortWrite)
+	}
+	<-conns
+
+	// the connection is closed after an error, to reconnect in the next write
+	if err := c.Write(ctx, writeLine("second")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, lines, "second")
+	select {
+	case <-conns:
+	case <-time.After(5 * time.Second):
+		t.Error("no new connection")
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+	c := New(Config{Name: "test", Network: "tcp", Address: address, Timeout: time.Second})
+	calls := 0
+	err = c.Write(context.Background(), func(w io.Writer) error {
+		calls++
+		return nil
+	})
+	if err == nil || calls != 0 {
+		t.Errorf("got %v after %d calls, want an error without writing", err, calls)
+	}
+}
@@ -1,9 +1,14 @@
 package oauth2util
 
-import "golang.org/x/oauth2"
+import (
+	"sync"
+
+	"golang.org/x/oauth2"
+)
 
 type TokenSourceSniffer struct {
 	ts           oauth2.TokenSource
+	mu           sync.Mutex // protects CurrentToken
 	CurrentToken *oauth2.Token
 }
 
@@ -15,7 +20,16 @@ func NewTokenSourceSniffer(ts oauth2.TokenSource, initialToken *oauth2.Token) *T
 func (s *TokenSourceSniffer) Token() (*oauth2.Token, error) {
 	t, err := s.ts.Token()
 	if err == nil {
+		s.mu.Lock()
 		s.CurrentToken = t
+		s.mu.Unlock()
 	}
 	return t, err
 }
+
+// Current returns the last provided token, it's safe to call concurrently with Token
+func (s *TokenSourceSniffer) Current() *oauth2.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.CurrentToken
+}
@@ -0,0 +1,137 @@
+package objectstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/parquet"
+)
+
+// buffer is an object being buffered in memory before uploading it
+type buffer struct {
+	partition   string
+	compression string
+	firstLog    string // UUID of the first log, which makes object names unique
+	created     time.Time
+	firstWrite  int // call to WriteLogs that wrote the first log
+	buf         bytes.Buffer
+	w           io.WriteCloser  // compresses into buf, nil once closed
+	parquet     *parquet.Writer // writes into buf instead, for Parquet objects
+	size        int64           // before compression
+	count       int
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newBuffer creates a buffer for an object that starts with the given log.
+// Parquet objects are created if parquetConfig is not nil, with their own compression.
+func newBuffer(partition string, first *integration.Log, compression string, parquetConfig *parquet.Config) (*buffer, error) {
+	b := &buffer{
+		partition:   partition,
+		compression: compression,
+		firstLog:    first.Uuid.String(),
+		created:     time.Now(),
+	}
+	if parquetConfig != nil {
+		pw, err := parquet.NewWriter(&b.buf, *parquetConfig)
+		if err != nil {
+			return nil, err
+		}
+		b.parquet = pw
+		b.w = nopWriteCloser{&b.buf} // closed along with the Parquet writer
+		return b, nil
+	}
+	switch compression {
+	case "gzip":
+		b.w = gzip.NewWriter(&b.buf)
+	case "zstd":
+		w, err := zstd.NewWriter(&b.buf)
+		if err != nil {
+			return nil, err
+		}
+		b.w = w
+	default:
+		b.w = nopWriteCloser{&b.buf}
+	}
+	return b, nil
+}
+
+func (b *buffer) write(line []byte) error {
+	if _, err := b.w.Write(line); err != nil {
+		return err
+	}
+	if _, err := b.w.Write([]byte{'\n'}); err != nil {
+		return err
+	}
+	b.size += int64(len(line)) + 1
+	b.count++
+	return nil
+}
+
+// writeParquet adds a log to a Parquet object
+func (b *buffer) writeParquet(l *integration.Log) error {
+	if err := b.parquet.Write(l); err != nil {
+		return err
+	}
+	b.size = b.parquet.Size()
+	b.count++
+	return nil
+}
+
+// bytes finishes the object and returns its contents. No more logs can be written after it.
+func (b *buffer) bytes() ([]byte, error) {
+	if b.w != nil {
+		if b.parquet != nil {
+			if err := b.parquet.Close(); err != nil {
+				return nil, err
+			}
+		}
+		if err := b.w.Close(); err != nil {
+			return nil, err
+		}
+		b.w = nil
+	}
+	return b.buf.Bytes(), nil
+}
+
+func (b *buffer) closed() bool {
+	return b.w == nil
+}
+
+// name returns the key of the object, relative to the prefix
+func (b *buffer) name() string {
+	name := b.partition + "/bugfender-" + b.created.UTC().Format("20060102T150405Z") + "-" + b.firstLog
+	if b.parquet != nil {
+		return name + parquet.Extension
+	}
+	name += ".ndjson"
+	switch b.compression {
+	case "gzip":
+		name += ".gz"
+	case "zstd":
+		name += ".zst"
+	}
+	return name
+}
+
+func (b *buffer) contentType() string {
+	if b.parquet != nil {
+		return parquet.ContentType
+	}
+	switch b.compression {
+	case "gzip":
+		return "application/gzip"
+	case "zstd":
+		return "application/zstd"
+	}
+	return "application/x-ndjson"
+}
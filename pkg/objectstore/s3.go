@@ -0,0 +1,312 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/parquet"
+)
+
+const (
+	// FormatNDJSON writes objects with one JSON document per line
+	FormatNDJSON = "ndjson"
+	// FormatParquet writes Parquet objects
+	FormatParquet = "parquet"
+)
+
+// S3Destination archives logs to objects in an S3-compatible object storage, partitioned by app, date and hour.
+// Logs are buffered in memory until an object is big or old enough, and the synchronization state only advances
+// once the objects are uploaded.
+type S3Destination struct {
+	client        *s3.Client
+	uploader      *manager.Uploader
+	bucket        string
+	prefix        string
+	compression   string
+	parquet       *parquet.Config // nil for NDJSON objects
+	maxObjectSize int64
+	flushInterval time.Duration
+	mapper        integration.Mapper
+	verbose       bool
+	buffers       map[string]*buffer // objects being buffered, by partition
+	writes        int                // number of calls to WriteLogs that buffered logs
+}
+
+// S3Config contains the parameters of the object storage
+type S3Config struct {
+	Bucket string
+	// Prefix of the object keys, eg. bugfender/ (default: none)
+	Prefix string
+	// Region of the bucket (default: us-east-1)
+	Region string
+	// Endpoint is the URL of S3-compatible storages, eg. http://localhost:9000 for MinIO (default: AWS)
+	Endpoint string
+	// PathStyle uses path-style URLs (endpoint/bucket/key), needed by most S3-compatible storages
+	PathStyle bool
+	// AccessKey and SecretKey are the credentials (default: the AWS credentials in the environment)
+	AccessKey string
+	SecretKey string
+	// Format of the objects: FormatNDJSON (default) or FormatParquet
+	Format string
+	// Compression of the objects: none, gzip (default) or zstd.
+	// Parquet objects compress their column chunks instead: none, snappy (default), gzip or zstd.
+	Compression string
+	// RowGroupSize is the size of the row groups of Parquet objects, before compression
+	// (default: parquet.DefaultRowGroupSize)
+	RowGroupSize int64
+	// MaxObjectSize is the size of the logs in an object, before compression, at which it's uploaded (default: 64 MB)
+	MaxObjectSize int64
+	// FlushInterval is the maximum time logs are buffered before uploading them (default: 5 minutes)
+	FlushInterval time.Duration
+	// PartSize is the size of the parts of multipart uploads, used for objects bigger than it (default: 8 MB)
+	PartSize int64
+	// Mapper converts logs to the JSON documents written (default: integration.DefaultMapper).
+	// Parquet objects have the columns of integration.Log instead.
+	Mapper  integration.Mapper
+	Verbose bool
+}
+
+var _ integration.BufferedLogWriter = &S3Destination{}
+
+// NewS3Destination creates an S3 destination with the given parameters, and checks that the bucket exists.
+// It is compulsory to call Close when done.
+func NewS3Destination(config S3Config) (*S3Destination, error) {
+	d := &S3Destination{
+		bucket:        config.Bucket,
+		prefix:        config.Prefix,
+		compression:   config.Compression,
+		maxObjectSize: config.MaxObjectSize,
+		flushInterval: config.FlushInterval,
+		mapper:        config.Mapper,
+		verbose:       config.Verbose,
+		buffers:       make(map[string]*buffer),
+	}
+	if d.bucket == "" {
+		return nil, fmt.Errorf("a bucket is needed")
+	}
+	switch config.Format {
+	case "", FormatNDJSON:
+		switch d.compression {
+		case "":
+			d.compression = "gzip"
+		case "none", "gzip", "zstd":
+		default:
+			return nil, fmt.Errorf("invalid compression %s, expected none, gzip or zstd", d.compression)
+		}
+	case FormatParquet:
+		d.parquet = &parquet.Config{Compression: config.Compression, RowGroupSize: config.RowGroupSize}
+		if err := parquet.CheckConfig(*d.parquet); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid format %s, expected %s or %s", config.Format, FormatNDJSON, FormatParquet)
+	}
+	if d.maxObjectSize <= 0 {
+		d.maxObjectSize = 64 << 20
+	}
+	if d.flushInterval <= 0 {
+		d.flushInterval = 5 * time.Minute
+	}
+	if d.mapper == nil {
+		d.mapper = integration.DefaultMapper
+	}
+	partSize := config.PartSize
+	if partSize < manager.MinUploadPartSize {
+		partSize = 8 << 20
+	}
+
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	ctx := context.Background()
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(region)}
+	if config.AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, "")))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %s", err)
+	}
+	d.client = s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = config.PathStyle
+		if config.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(config.Endpoint)
+		}
+	})
+	d.uploader = manager.NewUploader(d.client, func(u *manager.Uploader) {
+		u.PartSize = partSize
+	})
+	if _, err := d.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(d.bucket)}); err != nil {
+		return nil, fmt.Errorf("checking bucket %s: %s", d.bucket, err)
+	}
+	return d, nil
+}
+
+// WriteLogs buffers the logs. Objects that are big or old enough are uploaded before, so that
+// if the upload fails nothing is buffered and the logs can be written again.
+// The logs are encoded, and the objects of new partitions created, before buffering any log, so that if that fails
+// nothing is buffered either. If buffering a log fails after that, some objects contain part of the logs, so all the
+// logs buffered are discarded and integration.ErrBufferDiscarded returned, to download them again.
+func (d *S3Destination) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	if err := d.flush(ctx, false); err != nil {
+		return err
+	}
+	// stage the logs by partition
+	type staged struct {
+		b     *buffer
+		logs  []*integration.Log
+		lines [][]byte // for NDJSON objects
+	}
+	var partitions []*staged
+	byPartition := make(map[string]*staged)
+	for i := range logs {
+		l := &logs[i]
+		partition := partitionKey(l)
+		s, ok := byPartition[partition]
+		if !ok {
+			s = &staged{b: d.buffers[partition]}
+			if s.b == nil {
+				var err error
+				if s.b, err = newBuffer(partition, l, d.compression, d.parquet); err != nil {
+					return err
+				}
+				s.b.firstWrite = d.writes + 1
+			}
+			byPartition[partition] = s
+			partitions = append(partitions, s)
+		}
+		s.logs = append(s.logs, l)
+		if d.parquet == nil {
+			line, err := json.Marshal(d.mapper(*l))
+			if err != nil {
+				panic(err) // programming error
+			}
+			s.lines = append(s.lines, line)
+		}
+	}
+	// buffer them
+	for _, s := range partitions {
+		var err error
+		if s.b.parquet != nil {
+			for _, l := range s.logs {
+				if err = s.b.writeParquet(l); err != nil {
+					break
+				}
+			}
+		} else {
+			for _, line := range s.lines {
+				if err = s.b.write(line); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return d.discard(fmt.Errorf("buffering logs: %s", err))
+		}
+	}
+	for _, s := range partitions {
+		d.buffers[s.b.partition] = s.b
+	}
+	d.writes++
+	return nil
+}
+
+// Buffered returns how many of the last calls to WriteLogs buffered logs that are not uploaded yet
+func (d *S3Destination) Buffered() int {
+	if len(d.buffers) == 0 {
+		return 0
+	}
+	first := d.writes
+	for _, b := range d.buffers {
+		if b.firstWrite < first {
+			first = b.firstWrite
+		}
+	}
+	return d.writes - first + 1
+}
+
+// Flush uploads all the logs buffered
+func (d *S3Destination) Flush(ctx context.Context) error {
+	return d.flush(ctx, true)
+}
+
+// Close uploads the logs buffered
+func (d *S3Destination) Close(ctx context.Context) error {
+	return d.Flush(ctx)
+}
+
+// flush uploads the objects that are big or old enough, or all of them if all is true.
+// Objects whose upload failed are always uploaded again, because no more logs can be written to them.
+func (d *S3Destination) flush(ctx context.Context, all bool) error {
+	partitions := make([]string, 0, len(d.buffers))
+	for partition := range d.buffers {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+	for _, partition := range partitions {
+		b := d.buffers[partition]
+		if !all && !b.closed() && b.size < d.maxObjectSize && time.Since(b.created) < d.flushInterval {
+			continue
+		}
+		if err := d.upload(ctx, b); err != nil {
+			return err
+		}
+		delete(d.buffers, partition)
+	}
+	return nil
+}
+
+// upload uploads a buffered object, with a multipart upload if it's big.
+// If the object can't be completed, all the logs buffered are discarded.
+func (d *S3Destination) upload(ctx context.Context, b *buffer) error {
+	body, err := b.bytes()
+	if err != nil {
+		return d.discard(fmt.Errorf("completing %s: %s", d.prefix+b.name(), err))
+	}
+	key := d.prefix + b.name()
+	_, err = d.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(b.contentType()),
+	})
+	if err != nil {
+		return fmt.Errorf("uploading %s: %s", key, err)
+	}
+	if d.verbose {
+		log.Printf("Uploaded %d logs to %s", b.count, key)
+	}
+	return nil
+}
+
+// discard drops all the logs buffered, which are partially written, and returns err wrapped in
+// integration.ErrBufferDiscarded so that they are downloaded again
+func (d *S3Destination) discard(err error) error {
+	d.buffers = make(map[string]*buffer)
+	return fmt.Errorf("%w: %s", integration.ErrBufferDiscarded, err)
+}
+
+// partitionKey returns the partition of a log, in the Hive layout understood by most query engines
+func partitionKey(l *integration.Log) string {
+	t := l.Time.UTC()
+	return strings.Join([]string{
+		fmt.Sprintf("app=%d", l.App),
+		"date=" + t.Format("2006-01-02"),
+		"hour=" + t.Format("15"),
+	}, "/")
+}
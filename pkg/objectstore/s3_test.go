@@ -0,0 +1,167 @@
+package objectstore
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// bucket is a fake S3 bucket, which stores the objects uploaded and can reject the uploads
+type bucket struct {
+	mu      sync.Mutex
+	objects map[string]string // contents by key, uncompressed
+	reject  bool
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case r.Method == http.MethodHead && r.URL.Path == "/logs":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/logs/"):
+		if b.reject {
+			w.WriteHeader(http.StatusForbidden) // not retried by the SDK
+			_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>"))
+			return
+		}
+		body, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		contents, _ := ioutil.ReadAll(body)
+		b.objects[strings.TrimPrefix(r.URL.Path, "/logs/")] = string(contents)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (b *bucket) setReject(reject bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reject = reject
+}
+
+// contents returns the contents of the objects uploaded, sorted
+func (b *bucket) contents() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var contents []string
+	for _, c := range b.objects {
+		contents = append(contents, c)
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+func newTestDestination(t *testing.T) (*S3Destination, *bucket) {
+	b := &bucket{objects: make(map[string]string)}
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	d, err := NewS3Destination(S3Config{
+		Bucket:    "logs",
+		Endpoint:  server.URL,
+		PathStyle: true,
+		AccessKey: "key",
+		SecretKey: "secret",
+		Mapper:    func(l integration.Log) interface{} { return l.Text },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, b
+}
+
+// newLog returns a log of app 1 at the given time, with a UUID to make the object names unique
+func newLog(hour, minute int, text string) integration.Log {
+	return integration.Log{
+		Uuid: uuid.Must(uuid.NewV4()),
+		App:  1,
+		Time: time.Date(2021, 6, 1, hour, minute, 0, 0, time.UTC),
+		Text: text,
+	}
+}
+
+func TestWriteLogs(t *testing.T) {
+	d, b := newTestDestination(t)
+	ctx := context.Background()
+	logs := []integration.Log{
+		newLog(10, 0, "one"),
+		newLog(11, 0, "two"),
+		newLog(10, 1, "three"),
+	}
+	if err := d.WriteLogs(ctx, logs[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteLogs(ctx, logs[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Buffered(); got != 2 {
+		t.Errorf("got %d writes buffered, want 2", got)
+	}
+	if got := b.contents(); len(got) != 0 {
+		t.Errorf("got %v uploaded before flushing", got)
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Buffered(); got != 0 {
+		t.Errorf("got %d writes buffered after flushing, want 0", got)
+	}
+	want := []string{"\"one\"\n\"three\"\n", "\"two\"\n"}
+	if got := b.contents(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var partitions []string
+	for key := range b.objects { // not written anymore
+		partitions = append(partitions, key[:strings.LastIndex(key, "/")])
+	}
+	sort.Strings(partitions)
+	if want := []string{"app=1/date=2021-06-01/hour=10", "app=1/date=2021-06-01/hour=11"}; !reflect.DeepEqual(partitions, want) {
+		t.Errorf("got partitions %v, want %v", partitions, want)
+	}
+}
+
+func TestWriteLogsUploadFailed(t *testing.T) {
+	d, b := newTestDestination(t)
+	d.maxObjectSize = 1 // uploaded on the next write
+	ctx := context.Background()
+	if err := d.WriteLogs(ctx, []integration.Log{newLog(10, 0, "one")}); err != nil {
+		t.Fatal(err)
+	}
+	// the upload fails before buffering the logs, so they can be written again
+	b.setReject(true)
+	two := []integration.Log{newLog(10, 1, "two")}
+	if err := d.WriteLogs(ctx, two); err == nil {
+		t.Fatal("got no error with the upload rejected")
+	}
+	if got := d.Buffered(); got != 1 {
+		t.Errorf("got %d writes buffered, want 1", got)
+	}
+	// the upload is retried, and the destination keeps working
+	b.setReject(false)
+	if err := d.WriteLogs(ctx, two); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"\"one\"\n", "\"two\"\n"}
+	if got := b.contents(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
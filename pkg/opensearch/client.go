@@ -0,0 +1,240 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/opensearch-project/opensearch-go/v2"
+	"github.com/opensearch-project/opensearch-go/v2/opensearchutil"
+	"github.com/opensearch-project/opensearch-go/v2/signer/awsv2"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+type Client struct {
+	os          *opensearch.Client
+	index       string
+	pipeline    string // ingest pipeline of the logs
+	mapper      integration.Mapper
+	failureFunc func(context.Context, opensearchutil.BulkIndexerItem, opensearchutil.BulkIndexerResponseItem, error) // Per item
+}
+
+// Config contains the parameters to connect to OpenSearch
+type Config struct {
+	// Index is the index to write logs to. With ISM, it's the alias of the indices rolled over.
+	Index     string
+	Addresses []string
+	Username  string
+	Password  string
+	// SigV4Region signs requests with AWS Signature Version 4 for this region, with the credentials
+	// in the environment (default: no signing, use Username and Password)
+	SigV4Region string
+	// SigV4Service is the service name used to sign requests: es (managed clusters) or aoss (serverless)
+	SigV4Service string
+	// Mapper converts logs to documents (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+	// Pipeline is the ingest pipeline for logs (default: none)
+	Pipeline string
+	// Template installs an index template with the mappings of the log documents
+	Template bool
+	// ISM manages the log indices with an Index State Management policy, if not nil
+	ISM *ISMConfig
+}
+
+// NewClient creates an OpenSearch client with the given parameters
+// It is compulsory to call Close when done.
+func NewClient(config Config) (*Client, error) {
+	osConfig := opensearch.Config{
+		Addresses:     config.Addresses,
+		Username:      config.Username,
+		Password:      config.Password,
+		RetryOnStatus: []int{502, 503, 504, 429},
+		RetryBackoff:  func(i int) time.Duration { return time.Duration(i) * 100 * time.Millisecond },
+		MaxRetries:    5,
+	}
+	if config.SigV4Region != "" {
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(config.SigV4Region))
+		if err != nil {
+			return nil, fmt.Errorf("loading AWS credentials: %s", err)
+		}
+		service := config.SigV4Service
+		if service == "" {
+			service = "es"
+		}
+		osConfig.Signer, err = awsv2.NewSignerWithService(awsCfg, service)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := opensearch.NewClient(osConfig)
+	if err != nil {
+		return nil, err
+	}
+	oc := &Client{os: client}
+
+	// prepare the indices before writing anything
+	ctx := context.Background()
+	if config.Template {
+		if err := oc.putIndexTemplate(ctx, config.Index, config.ISM); err != nil {
+			return nil, err
+		}
+	}
+	if config.ISM != nil {
+		if err := oc.putISMPolicy(ctx, config.Index, config.ISM); err != nil {
+			return nil, err
+		}
+		if err := oc.bootstrapRolloverIndex(ctx, config.Index); err != nil {
+			return nil, err
+		}
+	}
+	if config.Pipeline != "" {
+		if err := oc.checkPipeline(ctx, config.Pipeline); err != nil {
+			return nil, err
+		}
+	}
+
+	oc.index = config.Index
+	oc.pipeline = config.Pipeline
+	oc.failureFunc = func(
+		ctx context.Context,
+		item opensearchutil.BulkIndexerItem,
+		res opensearchutil.BulkIndexerResponseItem, err error,
+	) {
+		if err != nil {
+			log.Printf("ERROR: %s", err)
+		} else {
+			log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
+		}
+	}
+	oc.mapper = config.Mapper
+	if oc.mapper == nil {
+		oc.mapper = integration.DefaultMapper
+	}
+	return oc, nil
+}
+
+// WriteLogs writes logs to OpenSearch, and waits until they are written.
+// It returns an error if any of them is not written, so that they are written again.
+func (oc *Client) WriteLogs(ctx context.Context, page []integration.Log) error {
+	items := make([]opensearchutil.BulkIndexerItem, len(page))
+	for i, l := range page {
+		doc, err := json.Marshal(oc.mapper(l))
+		if err != nil {
+			panic(err) // programming error
+		}
+		items[i] = opensearchutil.BulkIndexerItem{
+			Action:     "index",
+			DocumentID: l.Uuid.String(),
+			Body:       bytes.NewReader(doc),
+			OnFailure:  oc.failureFunc,
+		}
+	}
+	return oc.bulk(ctx, oc.index, oc.pipeline, items)
+}
+
+// bulk writes items to an index with a bulk indexer, and waits until they are written.
+// The failures of the items are logged, and returned as an error.
+func (oc *Client) bulk(ctx context.Context, index, pipeline string, items []opensearchutil.BulkIndexerItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var mu sync.Mutex
+	var flushErr error // last error of the requests, the indexer reports each one twice and the last time with details
+	indexer, err := opensearchutil.NewBulkIndexer(opensearchutil.BulkIndexerConfig{
+		Client:   oc.os,
+		Index:    index,
+		Pipeline: pipeline,
+		OnError: func(_ context.Context, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			flushErr = err
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating the indexer for %s: %s", index, err)
+	}
+	for _, item := range items {
+		if err := indexer.Add(ctx, item); err != nil {
+			_ = indexer.Close(ctx)
+			return err
+		}
+	}
+	if err := indexer.Close(ctx); err != nil {
+		return err
+	}
+	// items whose response couldn't be decoded are neither flushed nor failed
+	if flushed := indexer.Stats().NumFlushed; flushed != uint64(len(items)) {
+		err := fmt.Errorf("writing to %s: %d of %d documents not written", index, uint64(len(items))-flushed, len(items))
+		if flushErr != nil {
+			err = fmt.Errorf("%s: %s", err, flushErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Close frees resources. Logs and documents are already written when WriteLogs and WriteDocuments return.
+func (oc *Client) Close(_ context.Context) error {
+	return nil
+}
+
+// do performs a request to the OpenSearch API, returning an error if it's not successful.
+// The response is decoded into out, if not nil. If notFoundOK, a missing resource is not an error,
+// and false is returned.
+func (oc *Client) do(ctx context.Context, method, path string, body, out interface{}, notFoundOK bool) (bool, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return false, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := oc.os.Perform(req.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("%s %s: %s", method, path, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode == http.StatusNotFound && notFoundOK {
+		return false, nil
+	}
+	if res.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return false, fmt.Errorf("%s %s: %s: %s", method, path, res.Status, strings.TrimSpace(string(b)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return false, fmt.Errorf("%s %s: %s", method, path, err)
+		}
+	}
+	return true, nil
+}
+
+// checkPipeline returns an error if the pipeline doesn't exist
+func (oc *Client) checkPipeline(ctx context.Context, id string) error {
+	found, err := oc.do(ctx, http.MethodGet, "/_ingest/pipeline/"+id, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("pipeline %s does not exist", id)
+	}
+	return nil
+}
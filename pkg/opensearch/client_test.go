@@ -0,0 +1,116 @@
+package opensearch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// bulkServer returns a server that fails the bulk items whose document has a fail field, or all the requests if
+// status is not 200
+func bulkServer(t *testing.T, status int) (*httptest.Server, *int32) {
+	var items int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !strings.HasSuffix(req.URL.Path, "/_bulk") {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+			return
+		}
+		if status != http.StatusOK {
+			rw.WriteHeader(status)
+			return
+		}
+		var results []string
+		scanner := bufio.NewScanner(req.Body)
+		for scanner.Scan() { // action
+			scanner.Scan() // document
+			atomic.AddInt32(&items, 1)
+			if strings.Contains(scanner.Text(), `"fail"`) {
+				results = append(results, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`)
+			} else {
+				results = append(results, `{"index":{"status":201,"result":"created"}}`)
+			}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(rw, `{"took":1,"errors":true,"items":[%s]}`, strings.Join(results, ","))
+	}))
+	t.Cleanup(server.Close)
+	return server, &items
+}
+
+func newTestClient(t *testing.T, url string) *Client {
+	c, err := NewClient(Config{
+		Index:     "logs",
+		Addresses: []string{url},
+		Mapper: func(l integration.Log) interface{} {
+			doc := map[string]interface{}{"text": l.Text}
+			if l.Tag == "fail" {
+				doc["fail"] = true
+			}
+			return doc
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close(context.Background()) })
+	return c
+}
+
+func TestWriteLogs(t *testing.T) {
+	server, items := bulkServer(t, http.StatusOK)
+	c := newTestClient(t, server.URL)
+	logs := []integration.Log{{Text: "a"}, {Text: "b"}}
+	// the logs are written when WriteLogs returns, without closing the client
+	if err := c.WriteLogs(context.Background(), logs); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(items); got != 2 {
+		t.Errorf("got %d items, want 2", got)
+	}
+	if err := c.WriteLogs(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteLogsFailures(t *testing.T) {
+	server, _ := bulkServer(t, http.StatusOK)
+	c := newTestClient(t, server.URL)
+	logs := []integration.Log{{Text: "a"}, {Text: "b", Tag: "fail"}, {Text: "c"}}
+	err := c.WriteLogs(context.Background(), logs)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 documents not written") {
+		t.Errorf("got error %v, want 1 of 3 documents not written", err)
+	}
+
+	server, _ = bulkServer(t, http.StatusBadRequest)
+	c = newTestClient(t, server.URL)
+	if err := c.WriteLogs(context.Background(), logs); err == nil || !strings.Contains(err.Error(), "3 of 3") {
+		t.Errorf("got error %v, want 3 of 3 documents not written", err)
+	}
+}
+
+func TestWriteDocuments(t *testing.T) {
+	server, items := bulkServer(t, http.StatusOK)
+	c := newTestClient(t, server.URL)
+	w, err := c.Index("devices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []integration.Document{{ID: "1", Body: map[string]interface{}{"a": 1}}}
+	if err := w.WriteDocuments(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(items); got != 1 {
+		t.Errorf("got %d items, want 1", got)
+	}
+	docs = append(docs, integration.Document{ID: "2", Body: map[string]interface{}{"fail": true}})
+	if err := w.WriteDocuments(context.Background(), docs); err == nil {
+		t.Error("no error with a failed document")
+	}
+}
@@ -0,0 +1,63 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/opensearch-project/opensearch-go/v2/opensearchutil"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// documentWriter writes documents to a secondary index
+type documentWriter struct {
+	client *Client
+	index  string
+}
+
+var _ integration.Indexer = &Client{}
+
+// Index returns a DocumentWriter that writes to the given index
+func (oc *Client) Index(name string) (integration.DocumentWriter, error) {
+	return &documentWriter{client: oc, index: name}, nil
+}
+
+// WriteDocuments writes documents to the index, replacing the documents with the same ID, or combining them with
+// their scripts, and waits until they are written
+func (w *documentWriter) WriteDocuments(ctx context.Context, docs []integration.Document) error {
+	items := make([]opensearchutil.BulkIndexerItem, len(docs))
+	for i, d := range docs {
+		action := "index"
+		var body []byte
+		var err error
+		if d.Script != "" {
+			action = "update"
+			body, err = d.ScriptedUpsert()
+		} else {
+			body, err = json.Marshal(d.Body)
+		}
+		if err != nil {
+			return err
+		}
+		items[i] = w.item(action, d.ID, body)
+	}
+	return w.client.bulk(ctx, w.index, "", items)
+}
+
+func (w *documentWriter) item(action, id string, body []byte) opensearchutil.BulkIndexerItem {
+	item := opensearchutil.BulkIndexerItem{
+		Action:     action,
+		DocumentID: id,
+		Body:       bytes.NewReader(body),
+		OnFailure:  w.client.failureFunc,
+	}
+	if action == "update" {
+		item.RetryOnConflict = intPtr(3)
+	}
+	return item
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,169 @@
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ISMConfig is the Index State Management policy of the log indices:
+// they are rolled over when they reach a given age or size, and deleted after the retention period.
+type ISMConfig struct {
+	// RolloverAge is the minimum age to roll over an index, e.g. 1d (empty: no age condition)
+	RolloverAge string
+	// RolloverSize is the minimum size to roll over an index, e.g. 50gb (empty: no size condition)
+	RolloverSize string
+	// Retention is the age at which indices are deleted, e.g. 30d (empty: never)
+	Retention string
+}
+
+// templateName returns the name of the index template and ISM policy for an index
+func templateName(index string) string {
+	return "bugfender-" + index
+}
+
+// putIndexTemplate creates or replaces the index template of the log indices, which maps the
+// time fields as dates, the log text as full text and the rest of strings as keywords
+func (oc *Client) putIndexTemplate(ctx context.Context, index string, ism *ISMConfig) error {
+	settings := map[string]interface{}{}
+	if ism != nil {
+		settings["plugins.index_state_management.rollover_alias"] = index
+	}
+	text := map[string]interface{}{
+		"type": "text",
+		"fields": map[string]interface{}{
+			"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 1024},
+		},
+	}
+	date := map[string]interface{}{"type": "date"}
+	template := map[string]interface{}{
+		"index_patterns": []string{index, index + "-*"},
+		"priority":       100,
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": map[string]interface{}{
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"strings_as_keywords": map[string]interface{}{
+							"match_mapping_type": "string",
+							"mapping":            map[string]interface{}{"type": "keyword", "ignore_above": 1024},
+						},
+					},
+				},
+				"properties": map[string]interface{}{
+					"time":       date,
+					"gap_start":  date,
+					"gap_end":    date,
+					"@timestamp": date, // ECS output format
+					"text":       text,
+					"message":    text, // ECS output format
+				},
+			},
+		},
+	}
+	_, err := oc.do(ctx, http.MethodPut, "/_index_template/"+templateName(index), template, nil, false)
+	if err != nil {
+		return fmt.Errorf("installing index template: %s", err)
+	}
+	return nil
+}
+
+// ismPolicy returns the ISM policy of the log indices
+func ismPolicy(index string, config *ISMConfig) map[string]interface{} {
+	rollover := map[string]interface{}{}
+	if config.RolloverAge != "" {
+		rollover["min_index_age"] = config.RolloverAge
+	}
+	if config.RolloverSize != "" {
+		rollover["min_size"] = config.RolloverSize
+	}
+	hot := map[string]interface{}{
+		"name":        "hot",
+		"actions":     []interface{}{map[string]interface{}{"rollover": rollover}},
+		"transitions": []interface{}{},
+	}
+	states := []interface{}{hot}
+	if config.Retention != "" {
+		hot["transitions"] = []interface{}{
+			map[string]interface{}{
+				"state_name": "delete",
+				"conditions": map[string]interface{}{"min_index_age": config.Retention},
+			},
+		}
+		states = append(states, map[string]interface{}{
+			"name":        "delete",
+			"actions":     []interface{}{map[string]interface{}{"delete": map[string]interface{}{}}},
+			"transitions": []interface{}{},
+		})
+	}
+	return map[string]interface{}{
+		"policy": map[string]interface{}{
+			"description":   "Bugfender logs: rollover and retention of " + index,
+			"default_state": "hot",
+			"states":        states,
+			"ism_template": []interface{}{
+				map[string]interface{}{
+					"index_patterns": []string{index + "-*"},
+					"priority":       100,
+				},
+			},
+		},
+	}
+}
+
+// putISMPolicy creates or replaces the ISM policy of the log indices
+func (oc *Client) putISMPolicy(ctx context.Context, index string, config *ISMConfig) error {
+	if config.RolloverAge == "" && config.RolloverSize == "" {
+		return fmt.Errorf("the ISM policy needs a rollover age or size")
+	}
+	path := "/_plugins/_ism/policies/" + templateName(index)
+	// replacing a policy requires its current version
+	var current struct {
+		SeqNo       int64 `json:"_seq_no"`
+		PrimaryTerm int64 `json:"_primary_term"`
+	}
+	found, err := oc.do(ctx, http.MethodGet, path, nil, &current, true)
+	if err != nil {
+		return fmt.Errorf("installing ISM policy: %s", err)
+	}
+	if found {
+		path += fmt.Sprintf("?if_seq_no=%d&if_primary_term=%d", current.SeqNo, current.PrimaryTerm)
+	}
+	if _, err := oc.do(ctx, http.MethodPut, path, ismPolicy(index, config), nil, false); err != nil {
+		return fmt.Errorf("installing ISM policy: %s", err)
+	}
+	return nil
+}
+
+// bootstrapRolloverIndex creates the first index rolled over by ISM, with the write alias index,
+// unless the alias already exists
+func (oc *Client) bootstrapRolloverIndex(ctx context.Context, index string) error {
+	found, err := oc.do(ctx, http.MethodHead, "/_alias/"+index, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
+	found, err = oc.do(ctx, http.MethodHead, "/"+index, nil, nil, true)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%s is an index, it can't be used as a rollover alias", index)
+	}
+	first := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"plugins.index_state_management.rollover_alias": index,
+		},
+		"aliases": map[string]interface{}{
+			index: map[string]interface{}{"is_write_index": true},
+		},
+	}
+	_, err = oc.do(ctx, http.MethodPut, "/"+index+"-000001", first, nil, false)
+	if err != nil && !strings.Contains(err.Error(), "resource_already_exists_exception") {
+		return fmt.Errorf("creating the first index: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/backoff"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/httpheader"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+const (
+	// ProtocolHTTP exports logs with protobuf over HTTP
+	ProtocolHTTP = "http/protobuf"
+	// ProtocolGRPC exports logs with gRPC
+	ProtocolGRPC = "grpc"
+)
+
+// exportMethod is the gRPC method to export logs
+const exportMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// maxBatchBytes is the maximum size of the log records exported in one request, below the default gRPC limit (4 MB)
+const maxBatchBytes = 1 << 20
+
+// Client exports logs to an OpenTelemetry collector, or other servers that receive OTLP
+type Client struct {
+	url         string // for HTTP
+	conn        *grpc.ClientConn
+	headers     map[string]string
+	serviceName string
+	gzip        bool
+	batchSize   int
+	retries     int
+	httpClient  *http.Client
+}
+
+// Config contains the parameters to export logs with OTLP
+type Config struct {
+	// Endpoint is the base URL of the receiver, eg. http://localhost:4318 for HTTP or http://localhost:4317 for gRPC.
+	// With gRPC, the https scheme connects with TLS.
+	Endpoint string
+	// Protocol is ProtocolHTTP (default) or ProtocolGRPC
+	Protocol string
+	// Headers are sent with each request, eg. for authentication
+	Headers map[string]string
+	// ServiceName is the service.name resource attribute (default: bugfender)
+	ServiceName string
+	// Gzip compresses the requests
+	Gzip bool
+	// BatchSize is the maximum number of logs exported in one request (default: 1000)
+	BatchSize int
+	// Retries is the number of times a request is retried when the receiver asks to, 0 for none
+	Retries int
+	// InsecureSkipVerify skips the verification of the server certificate with gRPC (insecure)
+	InsecureSkipVerify bool
+	// Timeout of each request (default: 30s)
+	Timeout time.Duration
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates an OTLP client with the given parameters.
+// It is compulsory to call Close when done.
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		headers:     config.Headers,
+		serviceName: config.ServiceName,
+		gzip:        config.Gzip,
+		batchSize:   config.BatchSize,
+		retries:     config.Retries,
+		httpClient:  &http.Client{Timeout: config.Timeout},
+	}
+	if c.serviceName == "" {
+		c.serviceName = "bugfender"
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 1000
+	}
+	if c.retries < 0 {
+		return nil, fmt.Errorf("invalid number of retries %d", c.retries)
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = 30 * time.Second
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %s", config.Endpoint, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint %s, expected an http or https URL", config.Endpoint)
+	}
+	switch config.Protocol {
+	case "", ProtocolHTTP:
+		c.url = strings.TrimSuffix(config.Endpoint, "/") + "/v1/logs"
+	case ProtocolGRPC:
+		transport := grpc.WithInsecure()
+		if endpoint.Scheme == "https" {
+			// #nosec G402 InsecureSkipVerify is only set if the user asks for it
+			transport = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				InsecureSkipVerify: config.InsecureSkipVerify,
+			}))
+		}
+		// the connection is established in the background
+		if c.conn, err = grpc.Dial(endpoint.Host, transport); err != nil {
+			return nil, fmt.Errorf("connecting to %s: %s", endpoint.Host, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid protocol %s, expected %s or %s", config.Protocol, ProtocolHTTP, ProtocolGRPC)
+	}
+	return c, nil
+}
+
+// WriteLogs exports logs, in batches grouped by resource (app, device and app version)
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	observed := time.Now()
+	var batch []*resourceLogs
+	resources := make(map[string]*resourceLogs) // by encoded resource
+	count, size := 0, 0
+	for i := range logs {
+		doc := fields(&logs[i])
+		resource := c.encodeResource(doc)
+		record := encodeRecord(&logs[i], doc, observed)
+		if count == c.batchSize || (count > 0 && size+len(record) > maxBatchBytes) {
+			if err := c.export(ctx, encodeRequest(batch), count); err != nil {
+				return err
+			}
+			batch, resources, count, size = nil, make(map[string]*resourceLogs), 0, 0
+		}
+		r, ok := resources[string(resource)]
+		if !ok {
+			r = &resourceLogs{resource: resource}
+			resources[string(resource)] = r
+			batch = append(batch, r)
+			size += len(resource)
+		}
+		r.records = append(r.records, record)
+		count++
+		size += len(record)
+	}
+	if count > 0 {
+		return c.export(ctx, encodeRequest(batch), count)
+	}
+	return nil
+}
+
+// export sends an export request, retrying it while the receiver returns retryable errors.
+// Logs rejected in partial successes are logged, because the receiver accepted the request and exporting them again
+// would fail again.
+func (c *Client) export(ctx context.Context, req []byte, count int) error {
+	var res []byte
+	err := backoff.Retry(ctx, c.retries, time.Second, 30*time.Second, func() (time.Duration, bool, error) {
+		var retryAfter time.Duration
+		var retryable bool
+		var err error
+		if c.conn != nil {
+			res, retryAfter, retryable, err = c.exportGRPC(ctx, req)
+		} else {
+			res, retryAfter, retryable, err = c.exportHTTP(ctx, req)
+		}
+		return retryAfter, retryable, err
+	})
+	if err != nil {
+		return err
+	}
+	rejected, message, err := partialSuccess(res)
+	if err != nil {
+		return fmt.Errorf("exporting logs with OTLP: invalid response: %s", err)
+	}
+	if rejected > 0 {
+		log.Printf("ERROR: the OTLP receiver rejected %d of %d logs: %s", rejected, count, message)
+	} else if message != "" {
+		log.Printf("WARNING: the OTLP receiver accepted the logs with a warning: %s", message)
+	}
+	return nil
+}
+
+// exportHTTP sends an export request over HTTP, and returns the response, or the error and whether it's retryable,
+// with the time to wait if the receiver says so
+func (c *Client) exportHTTP(ctx context.Context, body []byte) ([]byte, time.Duration, bool, error) {
+	var contentEncoding string
+	if c.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, 0, false, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, 0, false, err
+		}
+		body = buf.Bytes()
+		contentEncoding = "gzip"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, true, fmt.Errorf("exporting logs with OTLP: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	msg, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, true, fmt.Errorf("exporting logs with OTLP: %s", err)
+	}
+	if res.StatusCode/100 == 2 {
+		return msg, 0, false, nil
+	}
+	err = fmt.Errorf("exporting logs with OTLP: %s", res.Status)
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, httpheader.RetryAfter(res.Header), true, err
+	}
+	return nil, 0, false, err
+}
+
+// exportGRPC sends an export request with gRPC, and returns the response, or the error and whether it's retryable,
+// with the time to wait if the receiver says so
+func (c *Client) exportGRPC(ctx context.Context, req []byte) ([]byte, time.Duration, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.httpClient.Timeout)
+	defer cancel()
+	if len(c.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.headers))
+	}
+	opts := []grpc.CallOption{grpc.ForceCodec(rawCodec{})}
+	if c.gzip {
+		opts = append(opts, grpc.UseCompressor("gzip"))
+	}
+	var res []byte
+	err := c.conn.Invoke(ctx, exportMethod, &req, &res, opts...)
+	if err == nil {
+		return res, 0, false, nil
+	}
+	s := status.Convert(err)
+	err = fmt.Errorf("exporting logs with OTLP: %s: %s", s.Code(), s.Message())
+	var retryAfter time.Duration
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+			retryAfter = info.RetryDelay.AsDuration()
+		}
+	}
+	switch s.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return nil, retryAfter, true, err
+	case codes.ResourceExhausted:
+		// only retryable if the receiver says when
+		return nil, retryAfter, retryAfter > 0, err
+	}
+	return nil, 0, false, err
+}
+
+// Close closes the gRPC connection, if any
+func (c *Client) Close(_ context.Context) error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// rawCodec sends and receives messages already encoded, as *[]byte
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
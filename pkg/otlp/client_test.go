@@ -0,0 +1,119 @@
+package otlp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// receiver returns a server that replies to each request with the given handlers in turn, counting the requests
+func receiver(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if req.URL.Path != "/v1/logs" || req.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected request %s %s", req.URL.Path, req.Header.Get("Content-Type"))
+		}
+		if body, _ := ioutil.ReadAll(req.Body); len(body) == 0 {
+			t.Error("empty request")
+		}
+		if int(n) > len(handlers) {
+			t.Errorf("unexpected request %d", n)
+			return
+		}
+		handlers[n-1](rw, req)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func respond(code int, retryAfter string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, _ *http.Request) {
+		if retryAfter != "" {
+			rw.Header().Set("Retry-After", retryAfter)
+		}
+		rw.WriteHeader(code)
+	}
+}
+
+func TestExportHTTPRetries(t *testing.T) {
+	server, requests := receiver(t,
+		respond(http.StatusTooManyRequests, "1"),
+		respond(http.StatusServiceUnavailable, ""),
+		respond(http.StatusOK, ""))
+	c, err := NewClient(Config{Endpoint: server.URL, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := c.WriteLogs(context.Background(), []integration.Log{{Text: "hello"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(requests); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want Retry-After (1s) to be respected", elapsed)
+	}
+}
+
+func TestExportHTTPErrors(t *testing.T) {
+	// retries exhausted
+	server, requests := receiver(t, respond(http.StatusServiceUnavailable, "1"), respond(http.StatusServiceUnavailable, "1"))
+	c, err := NewClient(Config{Endpoint: server.URL, Retries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.WriteLogs(context.Background(), []integration.Log{{Text: "hello"}})
+	if err == nil || !strings.Contains(err.Error(), "503") || atomic.LoadInt32(requests) != 2 {
+		t.Errorf("got error %v after %d requests, want 503 after 2", err, atomic.LoadInt32(requests))
+	}
+
+	// not retryable
+	server, requests = receiver(t, respond(http.StatusBadRequest, ""))
+	c, err = NewClient(Config{Endpoint: server.URL, Retries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.WriteLogs(context.Background(), []integration.Log{{Text: "hello"}})
+	if err == nil || !strings.Contains(err.Error(), "400") || atomic.LoadInt32(requests) != 1 {
+		t.Errorf("got error %v after %d requests, want 400 after 1", err, atomic.LoadInt32(requests))
+	}
+}
+
+func TestExportHTTPPartialSuccess(t *testing.T) {
+	var ps, res []byte
+	ps = protowire.AppendTag(ps, 1, protowire.VarintType)
+	ps = protowire.AppendVarint(ps, 1)
+	ps = protowire.AppendTag(ps, 2, protowire.BytesType)
+	ps = protowire.AppendString(ps, "too old")
+	res = protowire.AppendTag(res, 1, protowire.BytesType)
+	res = protowire.AppendBytes(res, ps)
+	server, requests := receiver(t,
+		func(rw http.ResponseWriter, _ *http.Request) { _, _ = rw.Write(res) },
+		func(rw http.ResponseWriter, _ *http.Request) { _, _ = rw.Write([]byte{0x0a, 0x05}) })
+	c, err := NewClient(Config{Endpoint: server.URL, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the rejected logs are not exported again, they would be rejected again
+	if err := c.WriteLogs(context.Background(), []integration.Log{{Text: "a"}, {Text: "b"}}); err != nil {
+		t.Errorf("partial success: got error %v", err)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+	// invalid responses are errors
+	if err := c.WriteLogs(context.Background(), []integration.Log{{Text: "a"}}); err == nil {
+		t.Error("expected an error with an invalid response")
+	}
+}
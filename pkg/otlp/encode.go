@@ -0,0 +1,289 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// scopeName is the name of the instrumentation scope of the log records
+const scopeName = "bugfender-integration"
+
+// severityNumbers are the OpenTelemetry severity numbers of the levels
+var severityNumbers = map[integration.Level]uint64{
+	integration.LevelTrace:   1,  // TRACE
+	integration.LevelDebug:   5,  // DEBUG
+	integration.LevelInfo:    9,  // INFO
+	integration.LevelWarning: 13, // WARN
+	integration.LevelError:   17, // ERROR
+	integration.LevelFatal:   21, // FATAL
+}
+
+// resourceAttributes are the attributes of the resource of a log, following the OpenTelemetry semantic conventions
+// when possible, and the fields of the logs they come from
+var resourceAttributes = []struct {
+	key   string
+	field string
+}{
+	{"service.name", ""}, // set from the configuration
+	{"service.version", "version.version"},
+	{"bugfender.app.id", "app"},
+	{"bugfender.app.build", "version.build"},
+	{"device.id", "device.udid"},
+	{"device.model.identifier", "device.type"},
+	{"bugfender.device.name", "device.name"},
+	{"os.version", "os_version"},
+	{"bugfender.device.language", "language"},
+	{"bugfender.device.timezone", "timezone"},
+}
+
+// logAttributes are the attributes of the log records following the OpenTelemetry semantic conventions,
+// by field. The other fields are attributes prefixed with "bugfender.".
+var logAttributes = map[string]string{
+	"uuid":        "log.record.uid",
+	"method":      "code.function",
+	"file":        "code.filepath",
+	"line":        "code.lineno",
+	"thread_name": "thread.name",
+}
+
+// omittedFields are the fields that are not attributes, because they are the body, time or severity of the records
+var omittedFields = map[string]bool{
+	"text": true, "time": true, "log_level": true, "log_level_name": true, "severity": true, "syslog_severity": true,
+}
+
+// resourceLogs are the log records of a resource
+type resourceLogs struct {
+	resource []byte // encoded Resource
+	records  [][]byte
+}
+
+// fields returns the fields of a log, as encoded in JSON documents, without empty values
+func fields(l *integration.Log) map[string]interface{} {
+	b, err := json.Marshal(l)
+	if err != nil {
+		panic(err) // programming error
+	}
+	var doc map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		panic(err) // programming error
+	}
+	for k, v := range doc {
+		if v == nil || v == "" {
+			delete(doc, k)
+		}
+	}
+	return doc
+}
+
+// encodeResource encodes the resource of a log, removing its fields from doc:
+//
+//	Resource { repeated KeyValue attributes = 1; }
+func (c *Client) encodeResource(doc map[string]interface{}) []byte {
+	var b []byte
+	for _, a := range resourceAttributes {
+		var v interface{} = c.serviceName
+		if a.field != "" {
+			var ok bool
+			if v, ok = doc[a.field]; !ok {
+				continue
+			}
+			delete(doc, a.field)
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeKeyValue(a.key, v))
+	}
+	return b
+}
+
+// encodeRecord encodes a log as a log record, with the remaining fields in doc as attributes:
+//
+//	LogRecord {
+//	  fixed64 time_unix_nano = 1; fixed64 observed_time_unix_nano = 11;
+//	  SeverityNumber severity_number = 2; string severity_text = 3;
+//	  AnyValue body = 5; repeated KeyValue attributes = 6;
+//	}
+func encodeRecord(l *integration.Log, doc map[string]interface{}, observed time.Time) []byte {
+	var b []byte
+	if !l.Time.IsZero() {
+		b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, uint64(l.Time.UnixNano()))
+	}
+	b = protowire.AppendTag(b, 11, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(observed.UnixNano()))
+	if n, ok := severityNumbers[l.Level]; ok {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, n)
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, l.Level.String())
+	}
+	b = protowire.AppendTag(b, 5, protowire.BytesType)
+	b = protowire.AppendBytes(b, encodeAnyValue(l.Text))
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		if !omittedFields[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key, ok := logAttributes[k]
+		if !ok {
+			key = "bugfender." + k
+		}
+		if key == "code.lineno" && doc[k] == json.Number("0") {
+			continue
+		}
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeKeyValue(key, doc[k]))
+	}
+	return b
+}
+
+// encodeRequest encodes an export request:
+//
+//	ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	ScopeLogs { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//	InstrumentationScope { string name = 1; }
+func encodeRequest(resources []*resourceLogs) []byte {
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendString(scope, scopeName)
+	var req []byte
+	for _, r := range resources {
+		var sl []byte
+		sl = protowire.AppendTag(sl, 1, protowire.BytesType)
+		sl = protowire.AppendBytes(sl, scope)
+		for _, record := range r.records {
+			sl = protowire.AppendTag(sl, 2, protowire.BytesType)
+			sl = protowire.AppendBytes(sl, record)
+		}
+		var rl []byte
+		rl = protowire.AppendTag(rl, 1, protowire.BytesType)
+		rl = protowire.AppendBytes(rl, r.resource)
+		rl = protowire.AppendTag(rl, 2, protowire.BytesType)
+		rl = protowire.AppendBytes(rl, sl)
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, rl)
+	}
+	return req
+}
+
+// encodeKeyValue encodes an attribute:
+//
+//	KeyValue { string key = 1; AnyValue value = 2; }
+func encodeKeyValue(key string, value interface{}) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, key)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, encodeAnyValue(value))
+	return b
+}
+
+// encodeAnyValue encodes a value decoded from JSON. Objects and arrays are encoded as JSON strings, and integers
+// that don't fit in an int64 as strings.
+//
+//	AnyValue { oneof value { string string_value = 1; bool bool_value = 2; int64 int_value = 3;
+//	  double double_value = 4; } }
+func encodeAnyValue(value interface{}) []byte {
+	var b []byte
+	switch v := value.(type) {
+	case string:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			b = protowire.AppendTag(b, 3, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(i))
+		} else if _, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			// too big for int_value, as a string to keep its precision
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendString(b, string(v))
+		} else if f, err := v.Float64(); err == nil {
+			b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(f))
+		} else {
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendString(b, string(v))
+		}
+	default:
+		s, err := json.Marshal(v)
+		if err != nil {
+			panic(err) // programming error
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, s)
+	}
+	return b
+}
+
+// partialSuccess decodes the partial success of an export response:
+//
+//	ExportLogsServiceResponse { ExportLogsPartialSuccess partial_success = 1; }
+//	ExportLogsPartialSuccess { int64 rejected_log_records = 1; string error_message = 2; }
+func partialSuccess(res []byte) (rejected int64, message string, err error) {
+	for len(res) > 0 {
+		num, typ, n := protowire.ConsumeTag(res)
+		if n < 0 {
+			return 0, "", protowire.ParseError(n)
+		}
+		res = res[n:]
+		if num != 1 || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, res)
+			if n < 0 {
+				return 0, "", protowire.ParseError(n)
+			}
+			res = res[n:]
+			continue
+		}
+		ps, n := protowire.ConsumeBytes(res)
+		if n < 0 {
+			return 0, "", protowire.ParseError(n)
+		}
+		res = res[n:]
+		for len(ps) > 0 {
+			num, typ, n := protowire.ConsumeTag(ps)
+			if n < 0 {
+				return 0, "", protowire.ParseError(n)
+			}
+			ps = ps[n:]
+			switch {
+			case num == 1 && typ == protowire.VarintType:
+				v, n := protowire.ConsumeVarint(ps)
+				if n < 0 {
+					return 0, "", protowire.ParseError(n)
+				}
+				rejected = int64(v)
+				ps = ps[n:]
+			case num == 2 && typ == protowire.BytesType:
+				v, n := protowire.ConsumeString(ps)
+				if n < 0 {
+					return 0, "", protowire.ParseError(n)
+				}
+				message = v
+				ps = ps[n:]
+			default:
+				n = protowire.ConsumeFieldValue(num, typ, ps)
+				if n < 0 {
+					return 0, "", protowire.ParseError(n)
+				}
+				ps = ps[n:]
+			}
+		}
+	}
+	return rejected, message, nil
+}
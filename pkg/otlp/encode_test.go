@@ -0,0 +1,202 @@
+package otlp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// field is a field of a protobuf message
+type field struct {
+	typ   protowire.Type
+	value uint64 // varint and fixed64 fields
+	bytes []byte // length-delimited fields
+}
+
+// decode decodes the fields of a protobuf message, by number
+func decode(t *testing.T, b []byte) map[protowire.Number][]field {
+	t.Helper()
+	fields := make(map[protowire.Number][]field)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := field{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("field %d: unexpected wire type %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields[num] = append(fields[num], f)
+	}
+	return fields
+}
+
+// one returns the only field with the given number and wire type
+func one(t *testing.T, fields map[protowire.Number][]field, num protowire.Number, typ protowire.Type) field {
+	t.Helper()
+	if len(fields[num]) != 1 {
+		t.Fatalf("field %d repeated %d times, want once", num, len(fields[num]))
+	}
+	f := fields[num][0]
+	if f.typ != typ {
+		t.Fatalf("field %d has wire type %d, want %d", num, f.typ, typ)
+	}
+	return f
+}
+
+// anyValue decodes an AnyValue into a string, bool, int64 or float64
+func anyValue(t *testing.T, b []byte) interface{} {
+	t.Helper()
+	v := decode(t, b)
+	switch {
+	case len(v[1]) > 0: // string_value
+		return string(one(t, v, 1, protowire.BytesType).bytes)
+	case len(v[2]) > 0: // bool_value
+		return protowire.DecodeBool(one(t, v, 2, protowire.VarintType).value)
+	case len(v[3]) > 0: // int_value
+		return int64(one(t, v, 3, protowire.VarintType).value)
+	case len(v[4]) > 0: // double_value
+		return math.Float64frombits(one(t, v, 4, protowire.Fixed64Type).value)
+	}
+	t.Fatal("empty AnyValue")
+	return nil
+}
+
+// attributes decodes repeated KeyValue fields
+func attributes(t *testing.T, kvs []field) map[string]interface{} {
+	t.Helper()
+	attrs := make(map[string]interface{})
+	for _, f := range kvs {
+		kv := decode(t, f.bytes)
+		key := string(one(t, kv, 1, protowire.BytesType).bytes)
+		attrs[key] = anyValue(t, one(t, kv, 2, protowire.BytesType).bytes)
+	}
+	return attrs
+}
+
+func TestEncode(t *testing.T) {
+	c := &Client{serviceName: "my-app"}
+	id := uuid.Must(uuid.FromString("3f2504e0-4f89-11d3-9a0c-0305e82c3301"))
+	logTime := time.Date(2023, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	observed := logTime.Add(time.Minute)
+	l := integration.Log{
+		Uuid:           id,
+		Time:           logTime,
+		Level:          integration.LevelError,
+		Text:           "failed",
+		App:            42,
+		DeviceUDID:     "udid-1",
+		VersionVersion: "1.2",
+		Method:         "main",
+		Line:           10,
+		Extra:          map[string]interface{}{"ratio": 0.5, "flag": true},
+	}
+	doc := fields(&l)
+	resource := c.encodeResource(doc)
+	req := encodeRequest([]*resourceLogs{{resource: resource, records: [][]byte{encodeRecord(&l, doc, observed)}}})
+
+	// ExportLogsServiceRequest.resource_logs = 1
+	rl := decode(t, one(t, decode(t, req), 1, protowire.BytesType).bytes)
+	// ResourceLogs.resource = 1, Resource.attributes = 1
+	res := decode(t, one(t, rl, 1, protowire.BytesType).bytes)
+	wantResource := map[string]interface{}{
+		"service.name":     "my-app",
+		"service.version":  "1.2",
+		"bugfender.app.id": int64(42),
+		"device.id":        "udid-1",
+	}
+	if got := attributes(t, res[1]); !equal(got, wantResource) {
+		t.Errorf("resource attributes = %v, want %v", got, wantResource)
+	}
+	// ResourceLogs.scope_logs = 2, ScopeLogs.scope = 1, InstrumentationScope.name = 1
+	sl := decode(t, one(t, rl, 2, protowire.BytesType).bytes)
+	scope := decode(t, one(t, sl, 1, protowire.BytesType).bytes)
+	if name := string(one(t, scope, 1, protowire.BytesType).bytes); name != scopeName {
+		t.Errorf("scope name = %s, want %s", name, scopeName)
+	}
+	// ScopeLogs.log_records = 2
+	record := decode(t, one(t, sl, 2, protowire.BytesType).bytes)
+	if got := one(t, record, 1, protowire.Fixed64Type).value; got != uint64(logTime.UnixNano()) {
+		t.Errorf("time_unix_nano = %d, want %d", got, logTime.UnixNano())
+	}
+	if got := one(t, record, 11, protowire.Fixed64Type).value; got != uint64(observed.UnixNano()) {
+		t.Errorf("observed_time_unix_nano = %d, want %d", got, observed.UnixNano())
+	}
+	if got := one(t, record, 2, protowire.VarintType).value; got != 17 {
+		t.Errorf("severity_number = %d, want 17 (ERROR)", got)
+	}
+	if got := string(one(t, record, 3, protowire.BytesType).bytes); got != "error" {
+		t.Errorf("severity_text = %s, want error", got)
+	}
+	if got := anyValue(t, one(t, record, 5, protowire.BytesType).bytes); got != "failed" {
+		t.Errorf("body = %v, want failed", got)
+	}
+	attrs := attributes(t, record[6])
+	wantAttrs := map[string]interface{}{
+		"log.record.uid":  id.String(),
+		"code.function":   "main",
+		"code.lineno":     int64(10),
+		"bugfender.ratio": 0.5,
+		"bugfender.flag":  true,
+	}
+	for k, want := range wantAttrs {
+		if attrs[k] != want {
+			t.Errorf("attribute %s = %v, want %v", k, attrs[k], want)
+		}
+	}
+	for _, k := range []string{"bugfender.text", "bugfender.time", "bugfender.device.udid", "bugfender.app"} {
+		if _, ok := attrs[k]; ok {
+			t.Errorf("unexpected attribute %s", k)
+		}
+	}
+}
+
+func TestPartialSuccess(t *testing.T) {
+	var ps []byte
+	ps = protowire.AppendTag(ps, 1, protowire.VarintType)
+	ps = protowire.AppendVarint(ps, 3)
+	ps = protowire.AppendTag(ps, 2, protowire.BytesType)
+	ps = protowire.AppendString(ps, "too old")
+	var res []byte
+	res = protowire.AppendTag(res, 1, protowire.BytesType)
+	res = protowire.AppendBytes(res, ps)
+	rejected, message, err := partialSuccess(res)
+	if err != nil || rejected != 3 || message != "too old" {
+		t.Errorf("got %d, %q, %v, want 3, \"too old\"", rejected, message, err)
+	}
+	if rejected, message, err := partialSuccess(nil); err != nil || rejected != 0 || message != "" {
+		t.Errorf("empty response: got %d, %q, %v", rejected, message, err)
+	}
+	if _, _, err := partialSuccess([]byte{0x0a, 0x05}); err == nil {
+		t.Error("expected an error with a truncated response")
+	}
+}
+
+func equal(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package parquet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// columns of the Parquet files, with their definitions in the schema definition language of parquet-go as types
+var columns = integration.Columns(columnType)
+
+// columnType returns the definition of a column, optional if nullable, and the conversion of its values
+func columnType(name string, kind integration.ColumnKind, nullable bool) (string, func(interface{}) interface{}) {
+	repetition := "required"
+	if nullable {
+		repetition = "optional"
+	}
+	var typ string
+	var convert func(interface{}) interface{}
+	switch kind {
+	case integration.ColumnUUID:
+		typ = "fixed_len_byte_array(16) %s (UUID)"
+		convert = func(v interface{}) interface{} { return v.(uuid.UUID).Bytes() }
+	case integration.ColumnTime:
+		typ = "int64 %s (TIMESTAMP(MICROS, true))"
+		convert = func(v interface{}) interface{} { return timestampMicros(v.(time.Time)) }
+	case integration.ColumnString:
+		typ = "binary %s (STRING)"
+		convert = func(v interface{}) interface{} { return []byte(v.(string)) }
+	case integration.ColumnInt64:
+		typ = "int64 %s"
+	case integration.ColumnInt, integration.ColumnSeverity:
+		typ = "int32 %s"
+		convert = func(v interface{}) interface{} { return int32(v.(int)) }
+	case integration.ColumnUint64:
+		// stored as signed, because parquet-go can't write unsigned 64-bit integers
+		typ = "int64 %s"
+		convert = func(v interface{}) interface{} { return int64(v.(uint64)) }
+	case integration.ColumnJSON:
+		typ = "binary %s (JSON)"
+	default:
+		panic(fmt.Sprintf("column %s: kind %d not supported in Parquet files", name, kind)) // programming error
+	}
+	return repetition + " " + fmt.Sprintf(typ, name), convert
+}
+
+// timestampMicros returns the microseconds since the Unix epoch
+func timestampMicros(t time.Time) int64 {
+	return t.Unix()*1e6 + int64(t.Nanosecond()/1e3)
+}
+
+// schemaDefinition returns the schema of the Parquet files
+func schemaDefinition() string {
+	var b strings.Builder
+	b.WriteString("message bugfender_log {\n")
+	for _, c := range columns {
+		b.WriteString("\t" + c.Type + ";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
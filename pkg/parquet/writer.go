@@ -0,0 +1,126 @@
+package parquet
+
+import (
+	"fmt"
+	"io"
+
+	goparquet "github.com/fraugster/parquet-go"
+	parquetformat "github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Extension is the file name extension of Parquet files
+const Extension = ".parquet"
+
+// ContentType is the media type of Parquet files
+const ContentType = "application/vnd.apache.parquet"
+
+// DefaultRowGroupSize is the default size of the row groups, before compression
+const DefaultRowGroupSize = 64 << 20
+
+// Config contains the parameters of the Parquet files
+type Config struct {
+	// Compression of the column chunks: none, snappy, gzip or zstd (default: snappy)
+	Compression string
+	// RowGroupSize is the approximate size of the row groups, before compression (default: DefaultRowGroupSize).
+	// Row groups are kept in memory until they're complete.
+	RowGroupSize int64
+}
+
+// Writer writes logs to a Parquet file, to query them with analytics tools like DuckDB or Spark.
+// The schema is derived from integration.Log: pointer fields are nullable columns, and times are timestamps.
+type Writer struct {
+	fw *goparquet.FileWriter
+}
+
+var schema = func() *parquetschema.SchemaDefinition {
+	sd, err := parquetschema.ParseSchemaDefinition(schemaDefinition())
+	if err != nil {
+		panic(err) // programming error
+	}
+	return sd
+}()
+
+func init() {
+	goparquet.RegisterBlockCompressor(parquetformat.CompressionCodec_ZSTD, zstdCompressor{})
+}
+
+// CheckConfig returns an error if the parameters are not valid
+func CheckConfig(config Config) error {
+	_, err := codec(config.Compression)
+	return err
+}
+
+// NewWriter creates a writer of a Parquet file to w. The file is only complete once the writer is closed.
+func NewWriter(w io.Writer, config Config) (*Writer, error) {
+	c, err := codec(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	rowGroupSize := config.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	return &Writer{
+		fw: goparquet.NewFileWriter(w,
+			goparquet.WithSchemaDefinition(schema),
+			goparquet.WithCompressionCodec(c),
+			goparquet.WithMaxRowGroupSize(rowGroupSize),
+			goparquet.WithCreator("bugfender-integration"),
+		),
+	}, nil
+}
+
+// Write adds a log to the file
+func (w *Writer) Write(l *integration.Log) error {
+	row := make(map[string]interface{}, len(columns))
+	for _, c := range columns {
+		if v := c.Value(l); v != nil {
+			row[c.Name] = v
+		}
+	}
+	return w.fw.AddData(row)
+}
+
+// Size returns the approximate size of the file: the row groups written plus the current one, before compression
+func (w *Writer) Size() int64 {
+	return w.fw.CurrentFileSize() + w.fw.CurrentRowGroupSize()
+}
+
+// Close writes the last row group and the footer of the file. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	return w.fw.Close()
+}
+
+func codec(compression string) (parquetformat.CompressionCodec, error) {
+	switch compression {
+	case "none":
+		return parquetformat.CompressionCodec_UNCOMPRESSED, nil
+	case "", "snappy":
+		return parquetformat.CompressionCodec_SNAPPY, nil
+	case "gzip":
+		return parquetformat.CompressionCodec_GZIP, nil
+	case "zstd":
+		return parquetformat.CompressionCodec_ZSTD, nil
+	}
+	return 0, fmt.Errorf("invalid Parquet compression %s, expected none, snappy, gzip or zstd", compression)
+}
+
+// zstdCompressor compresses column chunks with zstd, which parquet-go doesn't support out of the box
+type zstdCompressor struct{}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func (zstdCompressor) CompressBlock(block []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(block, nil), nil
+}
+
+func (zstdCompressor) DecompressBlock(block []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(block, nil)
+}
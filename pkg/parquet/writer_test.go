@@ -0,0 +1,113 @@
+package parquet
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func str(s string) *string { return &s }
+
+func TestRoundTrip(t *testing.T) {
+	logTime := time.Date(2023, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	id := uuid.Must(uuid.FromString("3f2504e0-4f89-11d3-9a0c-0305e82c3301"))
+	logs := []integration.Log{
+		{
+			Uuid:       id,
+			Time:       logTime,
+			Level:      integration.LevelError,
+			Text:       "failed",
+			App:        42,
+			IssueTitle: str("crash"),
+			Extra:      map[string]interface{}{"user_id": "u1", "text": "collides with a field"},
+		},
+		{Time: logTime, Level: integration.Level(99), Text: "no optional fields"},
+	}
+	for _, compression := range []string{"none", "snappy", "gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, Config{Compression: compression})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range logs {
+				if err := w.Write(&logs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := goparquet.NewFileReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.NumRows() != 2 {
+				t.Fatalf("%d rows, want 2", r.NumRows())
+			}
+			timeColumn := r.GetSchemaDefinition().SubSchema("time").SchemaElement()
+			if lt := timeColumn.GetLogicalType(); lt == nil || lt.TIMESTAMP == nil || lt.TIMESTAMP.Unit.MICROS == nil ||
+				!lt.TIMESTAMP.IsAdjustedToUTC {
+				t.Errorf("time column has logical type %v, want TIMESTAMP(MICROS, true)", lt)
+			}
+
+			row, err := r.NextRow()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]interface{}{
+				"uuid":           id.Bytes(),
+				"time":           logTime.UnixNano() / 1e3,
+				"log_level":      int32(integration.LevelError),
+				"text":           []byte("failed"),
+				"app":            int64(42),
+				"issue_title":    []byte("crash"),
+				"log_level_name": []byte("error"),
+				"extra":          []byte(`{"user_id":"u1"}`),
+			}
+			for name, v := range want {
+				if !equal(row[name], v) {
+					t.Errorf("column %s = %v, want %v", name, row[name], v)
+				}
+			}
+
+			row, err = r.NextRow()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// nullable columns without value are missing from the row, required ones are there
+			for _, name := range []string{"issue_title", "log_level_name", "severity", "extra"} {
+				if v, ok := row[name]; ok {
+					t.Errorf("column %s = %v, want null", name, v)
+				}
+			}
+			if !equal(row["text"], []byte("no optional fields")) || !equal(row["uuid"], uuid.Nil.Bytes()) {
+				t.Errorf("required columns text = %v and uuid = %v", row["text"], row["uuid"])
+			}
+			if _, err := r.NextRow(); err != io.EOF {
+				t.Errorf("got %v after the last row, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	if err := CheckConfig(Config{Compression: "brotli"}); err == nil {
+		t.Error("expected an error with an unsupported compression")
+	}
+}
+
+func equal(a, b interface{}) bool {
+	if ab, ok := a.([]byte); ok {
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(ab, bb)
+	}
+	return a == b
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// columns of the logs table
+var columns = integration.Columns(columnType)
+
+// columnType returns the PostgreSQL type of a column, with NOT NULL if required, and the conversion of its values
+func columnType(name string, kind integration.ColumnKind, nullable bool) (string, func(interface{}) interface{}) {
+	notNull := " NOT NULL"
+	if nullable {
+		notNull = ""
+	}
+	switch kind {
+	case integration.ColumnUUID:
+		return "uuid" + notNull, func(v interface{}) interface{} { return [16]byte(v.(uuid.UUID)) }
+	case integration.ColumnTime:
+		return "timestamptz" + notNull, nil
+	case integration.ColumnString:
+		return "text" + notNull, nil
+	case integration.ColumnInt64:
+		return "bigint" + notNull, nil
+	case integration.ColumnInt:
+		return "integer" + notNull, func(v interface{}) interface{} { return int32(v.(int)) }
+	case integration.ColumnUint64:
+		return "numeric(20)" + notNull, nil
+	case integration.ColumnSeverity:
+		return "smallint" + notNull, func(v interface{}) interface{} { return int16(v.(int)) }
+	case integration.ColumnJSON:
+		return "jsonb" + notNull, nil
+	}
+	panic(fmt.Sprintf("column %s: kind %d not supported in PostgreSQL", name, kind)) // programming error
+}
+
+// columnNames returns the names of the columns
+func columnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// row returns the values of the columns for a log
+func row(l *integration.Log) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		values[i] = c.Value(l)
+	}
+	return values
+}
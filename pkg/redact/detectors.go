@@ -0,0 +1,110 @@
+package redact
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Detector finds sensitive substrings in a text
+type Detector struct {
+	Name    string
+	pattern *regexp.Regexp
+	// validate discards false positives of the pattern matching s[start:end], can be nil
+	validate func(s string, start, end int) bool
+}
+
+// builtinDetectors are the detectors that can be enabled by name
+var builtinDetectors = map[string]Detector{
+	"email": {
+		Name:    "email",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	"ip": {
+		Name:     "ip",
+		pattern:  regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?i:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`),
+		validate: isIP,
+	},
+	"creditcard": {
+		Name:     "creditcard",
+		pattern:  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		validate: luhnValid,
+	},
+	"jwt": {
+		Name:    "jwt",
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+	},
+	"bearer": {
+		Name:    "bearer",
+		pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	},
+}
+
+// BuiltinDetectorNames is the list of detectors enabled by default
+var BuiltinDetectorNames = []string{"email", "ip", "creditcard", "jwt", "bearer"}
+
+// CustomDetector creates a detector from a user-provided regular expression
+func CustomDetector(name, expr string) (Detector, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Detector{}, err
+	}
+	return Detector{Name: name, pattern: re}, nil
+}
+
+// replace replaces every match of the detector in s with the result of repl
+func (d Detector) replace(s string, repl func(Detector) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range d.pattern.FindAllStringIndex(s, -1) {
+		if d.validate != nil && !d.validate(s, m[0], m[1]) {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(repl(d))
+		last = m[1]
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isIP(s string, start, end int) bool {
+	match := s[start:end]
+	if !strings.ContainsAny(match, "0123456789abcdefABCDEF") {
+		return false // "::" alone is most likely not an address
+	}
+	// an address can not be part of a word, eg. "Foo::bar"
+	if start > 0 && isWordChar(s[start-1]) || end < len(s) && isWordChar(s[end]) {
+		return false
+	}
+	return net.ParseIP(match) != nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// luhnValid checks the Luhn checksum used by credit card numbers
+func luhnValid(s string, start, end int) bool {
+	s = s[start:end]
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
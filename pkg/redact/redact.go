@@ -0,0 +1,244 @@
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Policy specifies how a field is redacted
+type Policy string
+
+const (
+	// Mask replaces the sensitive substrings found by the detectors
+	Mask Policy = "mask"
+	// Drop removes the value of the field
+	Drop Policy = "drop"
+	// Hash replaces the value of the field with its keyed HMAC, so that it can still be correlated
+	Hash Policy = "hash"
+)
+
+// DefaultFieldPolicies is the field policy specification used if none is provided
+const DefaultFieldPolicies = "text=mask key_value_value=mask"
+
+// mandatoryFields maps the JSON name of the redactable fields present in every log to their value
+var mandatoryFields = map[string]func(l *integration.Log) *string{
+	"device.udid": func(l *integration.Log) *string { return &l.DeviceUDID },
+	"device.name": func(l *integration.Log) *string { return &l.DeviceName },
+	"text":        func(l *integration.Log) *string { return &l.Text },
+	"method":      func(l *integration.Log) *string { return &l.Method },
+	"file":        func(l *integration.Log) *string { return &l.File },
+	"tag":         func(l *integration.Log) *string { return &l.Tag },
+	"thread_name": func(l *integration.Log) *string { return &l.ThreadName },
+	"url":         func(l *integration.Log) *string { return &l.URL },
+}
+
+// optionalFields maps the JSON name of the redactable optional fields to their value, which can be nil
+var optionalFields = map[string]func(l *integration.Log) **string{
+	"issue_title":           func(l *integration.Log) **string { return &l.IssueTitle },
+	"issue_markdown":        func(l *integration.Log) **string { return &l.IssueMarkdown },
+	"view_controller_title": func(l *integration.Log) **string { return &l.ViewControllerTitle },
+	"key_value_key":         func(l *integration.Log) **string { return &l.KeyValueKey },
+	"key_value_value":       func(l *integration.Log) **string { return &l.KeyValueValue },
+	"interaction_detail":    func(l *integration.Log) **string { return &l.InteractionDetail },
+	"js_xpath":              func(l *integration.Log) **string { return &l.JSXPath },
+}
+
+// Config configures a Redactor
+type Config struct {
+	// Detectors are the names of the built-in detectors to use
+	Detectors []string
+	// Patterns are additional regular expressions to mask
+	Patterns []string
+	// Fields maps field names (as in the JSON documents) to the policy to apply.
+	// Names that aren't fields of Log are fields in Log.Extra, which are masked if they aren't given a policy.
+	Fields map[string]Policy
+	// HMACKey is the secret key used by the Hash policy
+	HMACKey []byte
+}
+
+// Redactor removes personal information from logs before they are written
+type Redactor struct {
+	detectors []Detector
+	fields    map[string]Policy
+	hmacKey   []byte
+}
+
+var _ integration.LogProcessor = &Redactor{}
+
+// New creates a Redactor with the given configuration
+func New(config Config) (*Redactor, error) {
+	r := Redactor{
+		fields:  config.Fields,
+		hmacKey: config.HMACKey,
+	}
+	for _, name := range config.Detectors {
+		d, ok := builtinDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	for _, expr := range config.Patterns {
+		d, err := CustomDetector("custom", expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", expr, err)
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	for field, policy := range config.Fields {
+		_, mandatory := mandatoryFields[field]
+		_, optional := optionalFields[field]
+		if !mandatory && !optional && integration.IsKnownField(field) {
+			return nil, fmt.Errorf("field %q can not be redacted", field)
+		}
+		switch policy {
+		case Mask, Drop:
+		case Hash:
+			if len(config.HMACKey) == 0 {
+				return nil, fmt.Errorf("a key is needed to hash field %q", field)
+			}
+		default:
+			return nil, fmt.Errorf("unknown policy %q for field %q", policy, field)
+		}
+	}
+	return &r, nil
+}
+
+// ParseFieldPolicies parses a list of field=policy pairs, separated by spaces
+func ParseFieldPolicies(s string) (map[string]Policy, error) {
+	policies := make(map[string]Policy)
+	for _, pair := range strings.Fields(s) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid field policy %q, expected field=policy", pair)
+		}
+		policies[kv[0]] = Policy(kv[1])
+	}
+	return policies, nil
+}
+
+// FieldNames returns the names of the fields of Log that can be redacted
+func FieldNames() []string {
+	names := make([]string, 0, len(mandatoryFields)+len(optionalFields))
+	for name := range mandatoryFields {
+		names = append(names, name)
+	}
+	for name := range optionalFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProcessLogs redacts the logs in place
+func (r *Redactor) ProcessLogs(_ context.Context, logs []integration.Log) ([]integration.Log, error) {
+	for i := range logs {
+		r.redactLog(&logs[i])
+	}
+	return logs, nil
+}
+
+func (r *Redactor) redactLog(l *integration.Log) {
+	for field, policy := range r.fields {
+		if value, ok := mandatoryFields[field]; ok {
+			*value(l) = r.apply(policy, *value(l))
+			continue
+		}
+		getValue, ok := optionalFields[field]
+		if !ok {
+			continue // extra field
+		}
+		value := getValue(l)
+		if *value == nil {
+			continue
+		}
+		if policy == Drop {
+			*value = nil
+			continue
+		}
+		redacted := r.apply(policy, **value)
+		*value = &redacted
+	}
+	for field, value := range l.Extra {
+		policy, ok := r.fields[field]
+		if !ok {
+			policy = Mask
+		}
+		if policy == Drop {
+			delete(l.Extra, field)
+			continue
+		}
+		l.Extra[field] = r.applyValue(policy, value)
+	}
+}
+
+// applyValue redacts a value decoded from JSON: strings in objects and arrays are masked, and other values are hashed
+// as JSON
+func (r *Redactor) applyValue(policy Policy, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.apply(policy, v)
+	case map[string]interface{}:
+		if policy == Mask {
+			for k, e := range v {
+				v[k] = r.applyValue(policy, e)
+			}
+			return v
+		}
+	case []interface{}:
+		if policy == Mask {
+			for i, e := range v {
+				v[i] = r.applyValue(policy, e)
+			}
+			return v
+		}
+	case nil:
+		return nil
+	}
+	if policy == Hash {
+		b, err := json.Marshal(value)
+		if err != nil {
+			panic(err) // programming error
+		}
+		return r.hash(string(b))
+	}
+	return value // numbers and booleans can't be masked
+}
+
+func (r *Redactor) apply(policy Policy, s string) string {
+	switch policy {
+	case Mask:
+		return r.Mask(s)
+	case Hash:
+		return r.hash(s)
+	default: // Drop
+		return ""
+	}
+}
+
+// Mask replaces every sensitive substring found by the detectors in s
+func (r *Redactor) Mask(s string) string {
+	for _, d := range r.detectors {
+		s = d.replace(s, func(d Detector) string {
+			return "[REDACTED:" + d.Name + "]"
+		})
+	}
+	return s
+}
+
+func (r *Redactor) hash(s string) string {
+	if s == "" {
+		return s
+	}
+	mac := hmac.New(sha256.New, r.hmacKey)
+	_, _ = mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,158 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func str(s string) *string { return &s }
+
+func TestProcessLogs(t *testing.T) {
+	r, err := New(Config{
+		Detectors: BuiltinDetectorNames,
+		Fields: map[string]Policy{
+			"text":            Mask,
+			"device.udid":     Hash,
+			"device.name":     Drop,
+			"key_value_value": Mask,
+			"key_value_key":   Drop,
+			"issue_title":     Hash,
+			"user_id":         Hash,
+			"session_token":   Drop,
+		},
+		HMACKey: []byte("key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []integration.Log{{
+		DeviceUDID:    "udid-1",
+		DeviceName:    "John's iPhone",
+		Text:          "login john@example.com from 10.0.0.1",
+		Tag:           "john@example.com", // no policy
+		KeyValueKey:   str("email"),
+		KeyValueValue: str("john@example.com"),
+		Extra: map[string]interface{}{
+			"user_id":       json.Number("42"),
+			"session_token": "abc",
+			"contact":       map[string]interface{}{"emails": []interface{}{"john@example.com"}, "age": json.Number("30")},
+		},
+	}}
+	logs, err = r.ProcessLogs(context.Background(), logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := logs[0]
+	if want := "login [REDACTED:email] from [REDACTED:ip]"; l.Text != want {
+		t.Errorf("text = %q, want %q", l.Text, want)
+	}
+	if want := r.hash("udid-1"); l.DeviceUDID != want || len(want) != 64 {
+		t.Errorf("device.udid = %q, want %q", l.DeviceUDID, want)
+	}
+	if l.DeviceName != "" {
+		t.Errorf("device.name = %q, want it dropped", l.DeviceName)
+	}
+	if l.Tag != "john@example.com" {
+		t.Errorf("tag = %q, want it unchanged", l.Tag)
+	}
+	if l.KeyValueKey != nil {
+		t.Errorf("key_value_key = %q, want it dropped", *l.KeyValueKey)
+	}
+	if l.KeyValueValue == nil || *l.KeyValueValue != "[REDACTED:email]" {
+		t.Errorf("key_value_value = %v, want it masked", l.KeyValueValue)
+	}
+	if l.IssueTitle != nil {
+		t.Errorf("issue_title = %q, want it nil", *l.IssueTitle)
+	}
+	wantExtra := map[string]interface{}{
+		"user_id": r.hash("42"),
+		"contact": map[string]interface{}{"emails": []interface{}{"[REDACTED:email]"}, "age": json.Number("30")},
+	}
+	if !reflect.DeepEqual(l.Extra, wantExtra) {
+		t.Errorf("extra = %v, want %v", l.Extra, wantExtra)
+	}
+}
+
+func TestHashIsKeyed(t *testing.T) {
+	r1, _ := New(Config{HMACKey: []byte("key1")})
+	r2, _ := New(Config{HMACKey: []byte("key2")})
+	if r1.hash("udid") != r1.hash("udid") {
+		t.Error("hash is not deterministic")
+	}
+	if r1.hash("udid") == r2.hash("udid") {
+		t.Error("hash doesn't depend on the key")
+	}
+	if r1.hash("") != "" {
+		t.Error("empty values are hashed")
+	}
+}
+
+func TestNewValidatesFields(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config Config
+		ok     bool
+	}{
+		{"redactable field", Config{Fields: map[string]Policy{"text": Mask}}, true},
+		{"unknown field", Config{Fields: map[string]Policy{"custom": Drop}}, true},
+		{"known field not redactable", Config{Fields: map[string]Policy{"app": Drop}}, false},
+		{"unknown policy", Config{Fields: map[string]Policy{"text": "encrypt"}}, false},
+		{"hash without key", Config{Fields: map[string]Policy{"text": Hash}}, false},
+	} {
+		if _, err := New(tt.config); (err == nil) != tt.ok {
+			t.Errorf("%s: New() error = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestParseFieldPolicies(t *testing.T) {
+	got, err := ParseFieldPolicies(" text=mask  device.udid=hash ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]Policy{"text": Mask, "device.udid": Hash}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFieldPolicies() = %v, want %v", got, want)
+	}
+	if _, err := ParseFieldPolicies("text"); err == nil {
+		t.Error("ParseFieldPolicies() without a policy succeeded")
+	}
+}
+
+func TestProcessDecodedLogs(t *testing.T) {
+	r, err := New(Config{Detectors: BuiltinDetectorNames, Fields: map[string]Policy{"user_id": Hash}, HMACKey: []byte("key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unknown fields as returned by the API
+	var l integration.Log
+	err = json.Unmarshal([]byte(`{"text":"hi","contact":"john@example.com","nested":{"e":"john@example.com","n":1},"user_id":42}`), &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := r.ProcessLogs(context.Background(), []integration.Log{l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["contact"] != "[REDACTED:email]" {
+		t.Errorf("contact = %v, want it masked", doc["contact"])
+	}
+	wantNested := map[string]interface{}{"e": "[REDACTED:email]", "n": float64(1)}
+	if !reflect.DeepEqual(doc["nested"], wantNested) {
+		t.Errorf("nested = %v, want %v", doc["nested"], wantNested)
+	}
+	if doc["user_id"] != r.hash("42") {
+		t.Errorf("user_id = %v, want it hashed", doc["user_id"])
+	}
+}
@@ -0,0 +1,253 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// evictAfter is the time after which a session that received no logs is forgotten
+const evictAfter = 24 * time.Hour
+
+// maxRememberedLogs is the number of logs whose annotation is remembered, so that logs processed again (eg. when
+// a page is retried, or synchronized again after a restart) are annotated the same way and not counted twice.
+// It's the size of a page of logs.
+const maxRememberedLogs = 10000
+
+// Config configures a Tracker
+type Config struct {
+	// IdleTimeout is the time between logs of a device after which a new session starts
+	IdleTimeout time.Duration
+	// SplitOnGaps starts a new session after a gap in logs reporting
+	SplitOnGaps bool
+	// Summaries receives a summary document of every session updated, can be nil
+	Summaries integration.DocumentWriter
+}
+
+// Summary is the summary document of a session
+type Summary struct {
+	ID             string    `json:"session.id"`
+	App            int64     `json:"app"`
+	DeviceUDID     string    `json:"device.udid"`
+	DeviceName     string    `json:"device.name"`
+	DeviceType     string    `json:"device.type"`
+	VersionVersion string    `json:"version.version"`
+	VersionBuild   string    `json:"version.build"`
+	OSVersion      string    `json:"os_version"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	DurationMillis int64     `json:"duration_ms"`
+	LogCount       int64     `json:"log_count"`
+	// ErrorCount is the number of logs with error or fatal level
+	ErrorCount int64 `json:"error_count"`
+	// MaxSeverity is the highest severity of the logs in the session (see integration.Level.Severity)
+	MaxSeverity int `json:"max_severity"`
+}
+
+// key identifies the device and app version sessions belong to
+type key struct {
+	app            int64
+	device         string
+	versionVersion string
+	versionBuild   string
+}
+
+type session struct {
+	summary  Summary
+	sequence int64
+	last     time.Time // time of the last log
+	closed   bool      // a gap in logs reporting was found, the next log starts a new session
+	updated  time.Time // when the session was last updated, to evict it
+}
+
+// annotation is the session a log belongs to and its order in it
+type annotation struct {
+	session  string
+	sequence int64
+}
+
+// Tracker groups the logs of each device and app version into sessions.
+// Logs are annotated with the session they belong to (session.id) and their order in it (session.sequence).
+// Its state is saved in the state file, so sessions in progress continue after a restart.
+type Tracker struct {
+	config      Config
+	sessions    map[key]*session
+	annotations map[uuid.UUID]annotation // of the last logs processed
+	remembered  []uuid.UUID              // logs in annotations, oldest first
+}
+
+var _ integration.StatefulProcessor = &Tracker{}
+
+// NewTracker creates a session Tracker
+func NewTracker(config Config) *Tracker {
+	return &Tracker{
+		config:      config,
+		sessions:    make(map[key]*session),
+		annotations: make(map[uuid.UUID]annotation),
+	}
+}
+
+// ProcessLogs annotates the logs with their session, in place
+func (t *Tracker) ProcessLogs(ctx context.Context, logs []integration.Log) ([]integration.Log, error) {
+	// process the logs of each device in order of occurrence
+	order := make([]int, len(logs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &logs[order[i]], &logs[order[j]]
+		if ka, kb := keyOf(a), keyOf(b); ka != kb {
+			return ka.less(kb)
+		}
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.AbsoluteTime < b.AbsoluteTime
+	})
+
+	now := time.Now()
+	updated := make(map[*session]bool)
+	for _, i := range order {
+		l := &logs[i]
+		k := keyOf(l)
+		if a, ok := t.annotations[l.Uuid]; ok {
+			// processed again, the session already counts it
+			if s := t.sessions[k]; s != nil && s.summary.ID == a.session {
+				s.updated = now
+				updated[s] = true
+			}
+			annotate(l, a)
+			continue
+		}
+		s := t.sessions[k]
+		if s == nil || s.closed || absDuration(l.Time.Sub(s.last)) > t.config.IdleTimeout {
+			s = newSession(l)
+			t.sessions[k] = s
+		}
+		s.add(l)
+		s.updated = now
+		updated[s] = true
+		a := annotation{session: s.summary.ID, sequence: s.sequence}
+		t.remember(l.Uuid, a)
+		annotate(l, a)
+		if t.config.SplitOnGaps && (l.GapStart != nil || l.GapEnd != nil) {
+			s.closed = true
+		}
+	}
+	t.evict(now)
+
+	if t.config.Summaries != nil && len(updated) > 0 {
+		docs := make([]integration.Document, 0, len(updated))
+		for s := range updated {
+			docs = append(docs, integration.Document{ID: s.summary.ID, Body: s.summary})
+		}
+		if err := t.config.Summaries.WriteDocuments(ctx, docs); err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
+}
+
+// annotate sets the session of a log
+func annotate(l *integration.Log, a annotation) {
+	if l.Extra == nil {
+		l.Extra = make(map[string]interface{})
+	}
+	l.Extra["session.id"] = a.session
+	l.Extra["session.sequence"] = a.sequence
+}
+
+// remember remembers the annotation of a log, forgetting the oldest one if there are too many
+func (t *Tracker) remember(id uuid.UUID, a annotation) {
+	if id == uuid.Nil {
+		return
+	}
+	t.annotations[id] = a
+	t.remembered = append(t.remembered, id)
+	if len(t.remembered) > maxRememberedLogs {
+		delete(t.annotations, t.remembered[0])
+		t.remembered = t.remembered[1:]
+	}
+}
+
+// evict forgets the sessions that have not been updated for a while
+func (t *Tracker) evict(now time.Time) {
+	for k, s := range t.sessions {
+		if now.Sub(s.updated) > evictAfter {
+			delete(t.sessions, k)
+		}
+	}
+}
+
+func keyOf(l *integration.Log) key {
+	return key{
+		app:            l.App,
+		device:         l.DeviceUDID,
+		versionVersion: l.VersionVersion,
+		versionBuild:   l.VersionBuild,
+	}
+}
+
+func (k key) less(o key) bool {
+	if k.app != o.app {
+		return k.app < o.app
+	}
+	if k.device != o.device {
+		return k.device < o.device
+	}
+	if k.versionVersion != o.versionVersion {
+		return k.versionVersion < o.versionVersion
+	}
+	return k.versionBuild < o.versionBuild
+}
+
+// newSession starts a session with its first log.
+// The session ID is derived from the first log, so that the same ID is assigned if the logs are synchronized again.
+func newSession(first *integration.Log) *session {
+	return &session{
+		summary: Summary{
+			ID:             uuid.NewV5(first.Uuid, "session").String(),
+			App:            first.App,
+			DeviceUDID:     first.DeviceUDID,
+			VersionVersion: first.VersionVersion,
+			VersionBuild:   first.VersionBuild,
+			Start:          first.Time,
+			End:            first.Time,
+			MaxSeverity:    first.Level.Severity(),
+		},
+	}
+}
+
+func (s *session) add(l *integration.Log) {
+	s.sequence++
+	s.last = l.Time
+	sum := &s.summary
+	sum.DeviceName = l.DeviceName
+	sum.DeviceType = l.DeviceType
+	sum.OSVersion = l.OSVersion
+	if l.Time.Before(sum.Start) {
+		sum.Start = l.Time
+	}
+	if l.Time.After(sum.End) {
+		sum.End = l.Time
+	}
+	sum.DurationMillis = sum.End.Sub(sum.Start).Milliseconds()
+	sum.LogCount++
+	if l.Level == integration.LevelError || l.Level == integration.LevelFatal {
+		sum.ErrorCount++
+	}
+	if severity := l.Level.Severity(); severity > sum.MaxSeverity {
+		sum.MaxSeverity = severity
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
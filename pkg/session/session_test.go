@@ -0,0 +1,113 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// summaries is a destination of summaries that keeps the last one of each session
+type summaries map[string]Summary
+
+func (w summaries) WriteDocuments(_ context.Context, docs []integration.Document) error {
+	for _, doc := range docs {
+		w[doc.ID] = doc.Body.(Summary)
+	}
+	return nil
+}
+
+func testLogs(start time.Time, n int) []integration.Log {
+	logs := make([]integration.Log, n)
+	for i := range logs {
+		logs[i] = integration.Log{
+			Uuid:       uuid.Must(uuid.NewV4()),
+			Time:       start.Add(time.Duration(i) * time.Second),
+			App:        1,
+			DeviceUDID: "device",
+		}
+	}
+	return logs
+}
+
+func process(t *testing.T, tracker *Tracker, logs []integration.Log) {
+	t.Helper()
+	if _, err := tracker.ProcessLogs(context.Background(), logs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sequences(logs []integration.Log) []int64 {
+	s := make([]int64, len(logs))
+	for i := range logs {
+		s[i] = logs[i].Extra["session.sequence"].(int64)
+	}
+	return s
+}
+
+func TestProcessAgain(t *testing.T) {
+	sums := summaries{}
+	tracker := NewTracker(Config{IdleTimeout: time.Hour, Summaries: sums})
+	logs := testLogs(time.Now(), 3)
+	process(t, tracker, logs)
+	// the page is retried
+	again := make([]integration.Log, len(logs))
+	copy(again, logs)
+	for i := range again {
+		again[i].Extra = nil
+	}
+	process(t, tracker, again)
+	if got := sequences(again); got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("sequences processed again = %v, want [1 2 3]", got)
+	}
+	id := logs[0].Extra["session.id"].(string)
+	if again[0].Extra["session.id"] != id {
+		t.Errorf("session processed again = %v, want %s", again[0].Extra["session.id"], id)
+	}
+	if sums[id].LogCount != 3 {
+		t.Errorf("log count = %d, want 3", sums[id].LogCount)
+	}
+	// new logs continue the session
+	next := testLogs(logs[2].Time.Add(time.Second), 1)
+	process(t, tracker, next)
+	if next[0].Extra["session.id"] != id || next[0].Extra["session.sequence"] != int64(4) {
+		t.Errorf("next log annotated %v, want %s 4", next[0].Extra, id)
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	tracker := NewTracker(Config{IdleTimeout: time.Hour})
+	logs := testLogs(time.Now(), 2)
+	process(t, tracker, logs)
+	state, err := tracker.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewTracker(Config{IdleTimeout: time.Hour})
+	if err := restarted.RestoreState(state); err != nil {
+		t.Fatal(err)
+	}
+	// the last log is synchronized again after the restart, followed by a new one
+	again := []integration.Log{logs[1], testLogs(logs[1].Time.Add(time.Second), 1)[0]}
+	again[0].Extra = nil
+	process(t, restarted, again)
+	id := logs[0].Extra["session.id"]
+	for i, want := range []int64{2, 3} {
+		if again[i].Extra["session.id"] != id || again[i].Extra["session.sequence"] != want {
+			t.Errorf("log %d annotated %v, want %s %d", i, again[i].Extra, id, want)
+		}
+	}
+}
+
+func TestRememberedLogs(t *testing.T) {
+	tracker := NewTracker(Config{IdleTimeout: time.Hour})
+	process(t, tracker, testLogs(time.Now(), maxRememberedLogs+10))
+	if len(tracker.annotations) != maxRememberedLogs || len(tracker.remembered) != maxRememberedLogs {
+		t.Errorf("remembered %d annotations of %d logs, want %d", len(tracker.annotations),
+			len(tracker.remembered), maxRememberedLogs)
+	}
+}
@@ -0,0 +1,86 @@
+package session
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// state is the state of a Tracker saved in the state file
+type state struct {
+	Sessions []savedSession `json:"sessions"`
+	// Logs are the annotations of the last logs processed, oldest first
+	Logs []savedAnnotation `json:"logs"`
+}
+
+type savedSession struct {
+	Summary  Summary   `json:"summary"`
+	Sequence int64     `json:"sequence"`
+	Last     time.Time `json:"last"`
+	Closed   bool      `json:"closed,omitempty"`
+	Updated  time.Time `json:"updated"`
+}
+
+type savedAnnotation struct {
+	Log      uuid.UUID `json:"log"`
+	Session  string    `json:"session"`
+	Sequence int64     `json:"sequence"`
+}
+
+// StateKey identifies the state of the Tracker in the state file
+func (t *Tracker) StateKey() string {
+	return "sessions"
+}
+
+// SaveState returns the sessions in progress and the annotations of the last logs processed
+func (t *Tracker) SaveState() (json.RawMessage, error) {
+	st := state{
+		Sessions: make([]savedSession, 0, len(t.sessions)),
+		Logs:     make([]savedAnnotation, 0, len(t.remembered)),
+	}
+	for _, s := range t.sessions {
+		st.Sessions = append(st.Sessions, savedSession{
+			Summary:  s.summary,
+			Sequence: s.sequence,
+			Last:     s.last,
+			Closed:   s.closed,
+			Updated:  s.updated,
+		})
+	}
+	for _, id := range t.remembered {
+		a := t.annotations[id]
+		st.Logs = append(st.Logs, savedAnnotation{Log: id, Session: a.session, Sequence: a.sequence})
+	}
+	return json.Marshal(st)
+}
+
+// RestoreState restores a state returned by SaveState
+func (t *Tracker) RestoreState(b json.RawMessage) error {
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return err
+	}
+	t.sessions = make(map[key]*session, len(st.Sessions))
+	for _, saved := range st.Sessions {
+		s := &session{
+			summary:  saved.Summary,
+			sequence: saved.Sequence,
+			last:     saved.Last,
+			closed:   saved.Closed,
+			updated:  saved.Updated,
+		}
+		t.sessions[key{
+			app:            s.summary.App,
+			device:         s.summary.DeviceUDID,
+			versionVersion: s.summary.VersionVersion,
+			versionBuild:   s.summary.VersionBuild,
+		}] = s
+	}
+	t.annotations = make(map[uuid.UUID]annotation, len(st.Logs))
+	t.remembered = nil
+	for _, saved := range st.Logs {
+		t.remember(saved.Log, annotation{session: saved.Session, sequence: saved.Sequence})
+	}
+	return nil
+}
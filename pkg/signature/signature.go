@@ -0,0 +1,34 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Header contains the signature of a request, see Sign
+	Header = "X-Bugfender-Signature"
+	// TimestampHeader contains the time the request was sent, in Unix seconds
+	TimestampHeader = "X-Bugfender-Timestamp"
+)
+
+// Sign returns the signature of a request body sent at the given timestamp (in Unix seconds), like Bugfender signs
+// webhook notifications: the hex-encoded HMAC-SHA256 of "timestamp.body", keyed with the shared secret
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(timestamp))
+	_, _ = mac.Write([]byte("."))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets the timestamp and signature headers of a request with the given body, sent at the given time
+func SignRequest(req *http.Request, secret []byte, body []byte, sent time.Time) {
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(Header, "sha256="+Sign(secret, timestamp, body))
+}
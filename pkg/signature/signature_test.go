@@ -0,0 +1,29 @@
+package signature
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of `1700000000.{"id":"n1"}` with the key "secret"
+	const want = "5df397bab2242cdd9d0ebc2f78021bac1af24f5f162ebfab520c3cc3cfffffe0"
+	if got := Sign([]byte("secret"), "1700000000", []byte(`{"id":"n1"}`)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SignRequest(req, []byte("secret"), []byte(`{"id":"n1"}`), time.Unix(1700000000, 0))
+	if got := req.Header.Get(TimestampHeader); got != "1700000000" {
+		t.Errorf("timestamp %s, want 1700000000", got)
+	}
+	if got, want := req.Header.Get(Header), "sha256=5df397bab2242cdd9d0ebc2f78021bac1af24f5f162ebfab520c3cc3cfffffe0"; got != want {
+		t.Errorf("signature %s, want %s", got, want)
+	}
+}
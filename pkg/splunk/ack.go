@@ -0,0 +1,56 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/backoff"
+)
+
+type ackRequest struct {
+	Acks []int64 `json:"acks"`
+}
+
+type ackResponse struct {
+	Acks map[string]bool `json:"acks"`
+}
+
+// waitForAcks polls the acknowledgement endpoint until all the IDs are acknowledged, or the ack timeout expires
+func (c *Client) waitForAcks(ctx context.Context, ids []int64) error {
+	ackCtx, cancel := context.WithTimeout(ctx, c.ackTimeout)
+	defer cancel()
+	endpoint := "/services/collector/ack?" + url.Values{"channel": {c.channel}}.Encode()
+	boff := backoff.NewExponential(500*time.Millisecond, 10*time.Second)
+	pending := ids
+	for {
+		boff.Wait(ackCtx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if ackCtx.Err() != nil {
+			return fmt.Errorf("logs not acknowledged by Splunk after %s (ack IDs %s)", c.ackTimeout, ackIDsString(pending))
+		}
+		body, err := json.Marshal(ackRequest{Acks: pending})
+		if err != nil {
+			return err
+		}
+		var res ackResponse
+		if err := c.post(ackCtx, endpoint, body, &res); err != nil {
+			return err
+		}
+		var stillPending []int64
+		for _, id := range pending {
+			if !res.Acks[strconv.FormatInt(id, 10)] {
+				stillPending = append(stillPending, id)
+			}
+		}
+		if len(stillPending) == 0 {
+			return nil
+		}
+		pending = stillPending
+	}
+}
@@ -0,0 +1,317 @@
+package splunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+const (
+	// EndpointEvent sends each log as an event with its own time and metadata
+	EndpointEvent = "event"
+	// EndpointRaw sends the logs as raw lines, which Splunk parses according to the sourcetype
+	EndpointRaw = "raw"
+)
+
+// maxBatchBytes is the maximum size of the events sent in one request, before compression
+const maxBatchBytes = 1 << 20
+
+// Client sends logs to the Splunk HTTP Event Collector (HEC)
+type Client struct {
+	url        string
+	token      string
+	endpoint   string
+	channel    string
+	metadata   metadataTemplates
+	batchSize  int
+	gzip       bool
+	ack        bool
+	ackTimeout time.Duration
+	mapper     integration.Mapper
+	httpClient *http.Client
+}
+
+// Config contains the parameters to send logs to Splunk
+type Config struct {
+	// URL is the base URL of the HTTP Event Collector, eg. https://splunk:8088
+	URL string
+	// Token is the HEC token
+	Token string
+	// Endpoint is EndpointEvent (default) or EndpointRaw
+	Endpoint string
+	// Index, Source, SourceType and Host are the metadata of the events, which can contain {field}
+	// placeholders replaced by the values of each log, eg. "bugfender:{tag}" (default: the token defaults)
+	Index      string
+	Source     string
+	SourceType string
+	Host       string
+	// BatchSize is the maximum number of logs sent in one request (default: 1000)
+	BatchSize int
+	// Gzip compresses the requests
+	Gzip bool
+	// Ack waits for the indexer acknowledgement of the logs, which must be enabled in the token,
+	// so that WriteLogs only succeeds once the logs are safely indexed
+	Ack bool
+	// AckTimeout is the time to wait for acknowledgements before failing (default: 2 minutes)
+	AckTimeout time.Duration
+	// Mapper converts logs to the JSON documents sent (default: integration.DefaultMapper)
+	Mapper integration.Mapper
+	// Timeout of each request (default: 30s)
+	Timeout time.Duration
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates a Splunk HEC client with the given parameters
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		url:        strings.TrimSuffix(config.URL, "/"),
+		token:      config.Token,
+		endpoint:   config.Endpoint,
+		channel:    uuid.Must(uuid.NewV4()).String(),
+		batchSize:  config.BatchSize,
+		gzip:       config.Gzip,
+		ack:        config.Ack,
+		ackTimeout: config.AckTimeout,
+		mapper:     config.Mapper,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}
+	if c.token == "" {
+		return nil, fmt.Errorf("a HEC token is needed")
+	}
+	switch c.endpoint {
+	case "":
+		c.endpoint = EndpointEvent
+	case EndpointEvent, EndpointRaw:
+	default:
+		return nil, fmt.Errorf("invalid endpoint %s, expected %s or %s", c.endpoint, EndpointEvent, EndpointRaw)
+	}
+	var err error
+	for _, t := range []struct {
+		template **template
+		value    string
+	}{
+		{&c.metadata.index, config.Index},
+		{&c.metadata.source, config.Source},
+		{&c.metadata.sourceType, config.SourceType},
+		{&c.metadata.host, config.Host},
+	} {
+		if *t.template, err = parseTemplate(t.value); err != nil {
+			return nil, err
+		}
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = 1000
+	}
+	if c.ackTimeout <= 0 {
+		c.ackTimeout = 2 * time.Minute
+	}
+	if c.mapper == nil {
+		c.mapper = integration.DefaultMapper
+	}
+	if c.httpClient.Timeout == 0 {
+		c.httpClient.Timeout = 30 * time.Second
+	}
+	return c, nil
+}
+
+// metadata are the Splunk metadata of an event. Empty values use the defaults of the token.
+type metadata struct {
+	Index      string `json:"index,omitempty"`
+	Source     string `json:"source,omitempty"`
+	SourceType string `json:"sourcetype,omitempty"`
+	Host       string `json:"host,omitempty"`
+}
+
+type metadataTemplates struct {
+	index, source, sourceType, host *template
+}
+
+func (t *metadataTemplates) execute(l *integration.Log) metadata {
+	return metadata{
+		Index:      t.index.execute(l),
+		Source:     t.source.execute(l),
+		SourceType: t.sourceType.execute(l),
+		Host:       t.host.execute(l),
+	}
+}
+
+// event is an event sent to the event endpoint
+type event struct {
+	Time epochTime `json:"time"`
+	metadata
+	Event interface{} `json:"event"`
+}
+
+// epochTime is encoded as seconds since the epoch, with milliseconds
+type epochTime time.Time
+
+func (t epochTime) MarshalJSON() ([]byte, error) {
+	ms := time.Time(t).UnixNano() / int64(time.Millisecond)
+	return []byte(fmt.Sprintf("%d.%03d", ms/1000, ms%1000)), nil
+}
+
+// batch is a request being prepared
+type batch struct {
+	metadata metadata // only for the raw endpoint
+	body     bytes.Buffer
+	count    int
+}
+
+// WriteLogs sends logs to Splunk, in batches.
+// With Ack, it waits until Splunk acknowledges that all logs are indexed.
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	var ackIDs []int64
+	send := func(b *batch) error {
+		ackID, err := c.send(ctx, b)
+		if err != nil {
+			return err
+		}
+		if ackID != nil {
+			ackIDs = append(ackIDs, *ackID)
+		}
+		return nil
+	}
+	batches := make(map[metadata]*batch) // the raw endpoint needs a batch for each combination of metadata
+	var order []metadata
+	for i := range logs {
+		l := &logs[i]
+		md := c.metadata.execute(l)
+		var line []byte
+		var err error
+		if c.endpoint == EndpointEvent {
+			line, err = json.Marshal(event{Time: epochTime(l.Time), metadata: md, Event: c.mapper(*l)})
+			md = metadata{}
+		} else {
+			line, err = json.Marshal(c.mapper(*l))
+		}
+		if err != nil {
+			panic(err) // programming error
+		}
+		b, ok := batches[md]
+		if !ok {
+			b = &batch{metadata: md}
+			batches[md] = b
+			order = append(order, md)
+		}
+		if b.count == c.batchSize || (b.count > 0 && b.body.Len()+len(line) > maxBatchBytes) {
+			if err := send(b); err != nil {
+				return err
+			}
+			b.body.Reset()
+			b.count = 0
+		}
+		b.body.Write(line)
+		b.body.WriteByte('\n')
+		b.count++
+	}
+	for _, md := range order {
+		if b := batches[md]; b.count > 0 {
+			if err := send(b); err != nil {
+				return err
+			}
+		}
+	}
+	if len(ackIDs) > 0 {
+		return c.waitForAcks(ctx, ackIDs)
+	}
+	return nil
+}
+
+type response struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+// send sends a batch, and returns its acknowledgement ID if acknowledgements are enabled
+func (c *Client) send(ctx context.Context, b *batch) (*int64, error) {
+	endpoint := "/services/collector/" + c.endpoint
+	if c.endpoint == EndpointRaw {
+		q := url.Values{}
+		for _, kv := range [][2]string{
+			{"index", b.metadata.Index},
+			{"source", b.metadata.Source},
+			{"sourcetype", b.metadata.SourceType},
+			{"host", b.metadata.Host},
+		} {
+			if kv[1] != "" {
+				q.Set(kv[0], kv[1])
+			}
+		}
+		if len(q) > 0 {
+			endpoint += "?" + q.Encode()
+		}
+	}
+	var res response
+	if err := c.post(ctx, endpoint, b.body.Bytes(), &res); err != nil {
+		return nil, err
+	}
+	if !c.ack {
+		return nil, nil
+	}
+	if res.AckID == nil {
+		return nil, fmt.Errorf("sending logs to Splunk: no acknowledgement ID, indexer acknowledgement must be enabled in the HEC token")
+	}
+	return res.AckID, nil
+}
+
+// post sends a request to the HEC and decodes the response into out
+func (c *Client) post(ctx context.Context, endpoint string, body []byte, out interface{}) error {
+	var contentEncoding string
+	if c.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+		contentEncoding = "gzip"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+c.token)
+	req.Header.Set("X-Splunk-Request-Channel", c.channel)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending logs to Splunk: %s", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("sending logs to Splunk: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("sending logs to Splunk: %s", err)
+	}
+	return nil
+}
+
+// ackIDsString returns the IDs separated by commas, for messages
+func ackIDsString(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(s, ",")
+}
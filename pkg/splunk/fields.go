@@ -0,0 +1,76 @@
+package splunk
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// fields return the value of each log field that can be used in metadata templates
+var fields = map[string]func(l *integration.Log) string{
+	"app":         func(l *integration.Log) string { return strconv.FormatInt(l.App, 10) },
+	"level":       func(l *integration.Log) string { return l.Level.String() },
+	"device_name": func(l *integration.Log) string { return l.DeviceName },
+	"device_type": func(l *integration.Log) string { return l.DeviceType },
+	"device_udid": func(l *integration.Log) string { return l.DeviceUDID },
+	"version":     func(l *integration.Log) string { return l.VersionVersion },
+	"build":       func(l *integration.Log) string { return l.VersionBuild },
+	"os_version":  func(l *integration.Log) string { return l.OSVersion },
+	"language":    func(l *integration.Log) string { return l.Language },
+	"tag":         func(l *integration.Log) string { return l.Tag },
+	"type":        func(l *integration.Log) string { return l.Type },
+}
+
+// FieldNames returns the names of the fields that can be used in templates, sorted
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var placeholderRegexp = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// template is a metadata value with {field} placeholders, which are replaced by the values of each log
+type template struct {
+	parts []string                          // literal text, between placeholders
+	funcs []func(l *integration.Log) string // placeholders, after each part
+}
+
+// parseTemplate parses a template like "bugfender:{tag}"
+func parseTemplate(s string) (*template, error) {
+	t := &template{}
+	last := 0
+	for _, m := range placeholderRegexp.FindAllStringSubmatchIndex(s, -1) {
+		name := s[m[2]:m[3]]
+		f, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field {%s} in %q, valid fields: %s", name, s, strings.Join(FieldNames(), " "))
+		}
+		t.parts = append(t.parts, s[last:m[0]])
+		t.funcs = append(t.funcs, f)
+		last = m[1]
+	}
+	t.parts = append(t.parts, s[last:])
+	return t, nil
+}
+
+// execute returns the value of the template for a log
+func (t *template) execute(l *integration.Log) string {
+	if len(t.funcs) == 0 {
+		return t.parts[0]
+	}
+	var b strings.Builder
+	for i, f := range t.funcs {
+		b.WriteString(t.parts[i])
+		b.WriteString(f(l))
+	}
+	b.WriteString(t.parts[len(t.parts)-1])
+	return b.String()
+}
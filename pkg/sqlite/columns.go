@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// timeFormat is the format of the times stored, which sorts like the times and is understood by the SQLite
+// date and time functions
+const timeFormat = "2006-01-02T15:04:05.000000Z"
+
+// columns of the logs table
+var columns = integration.Columns(columnType)
+
+// columnType returns the SQLite type of a column, with NOT NULL if required, and the conversion of its values
+func columnType(name string, kind integration.ColumnKind, nullable bool) (string, func(interface{}) interface{}) {
+	notNull := " NOT NULL"
+	if nullable {
+		notNull = ""
+	}
+	switch kind {
+	case integration.ColumnUUID:
+		return "TEXT" + notNull, func(v interface{}) interface{} { return v.(uuid.UUID).String() }
+	case integration.ColumnTime:
+		return "TEXT" + notNull, func(v interface{}) interface{} { return v.(time.Time).UTC().Format(timeFormat) }
+	case integration.ColumnString:
+		return "TEXT" + notNull, nil
+	case integration.ColumnInt64:
+		return "INTEGER" + notNull, nil
+	case integration.ColumnInt, integration.ColumnSeverity:
+		return "INTEGER" + notNull, func(v interface{}) interface{} { return int64(v.(int)) }
+	case integration.ColumnUint64:
+		// stored as signed, SQLite integers are signed 64-bit integers
+		return "INTEGER" + notNull, func(v interface{}) interface{} { return int64(v.(uint64)) }
+	case integration.ColumnJSON:
+		return "TEXT" + notNull, func(v interface{}) interface{} { return string(v.([]byte)) }
+	}
+	panic(fmt.Sprintf("column %s: kind %d not supported in SQLite", name, kind)) // programming error
+}
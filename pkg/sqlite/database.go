@@ -0,0 +1,218 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the sqlite driver
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Destination writes logs to a SQLite database file, to search them locally.
+// The text of the logs is indexed with FTS5 in the logs_fts table, and the cursor of the synchronization
+// is saved in the cursors table, in the same transaction as the logs.
+type Destination struct {
+	db      *sql.DB
+	insert  string
+	verbose bool
+}
+
+// Config contains the parameters of the database
+type Config struct {
+	// Path of the database file, created if it doesn't exist
+	Path    string
+	Verbose bool
+}
+
+var _ integration.TransactionalLogWriter = &Destination{}
+
+// NewDestination opens the database, in WAL mode so it can be read while logs are written, and creates the tables
+// or adds the columns missing.
+// It is compulsory to call Close when done.
+func NewDestination(config Config) (*Destination, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("a database path is needed")
+	}
+	db, err := sql.Open("sqlite", config.Path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // writes are serialized anyway, and the pragmas apply to the connection
+	d := &Destination{db: db, verbose: config.Verbose}
+	if err := d.migrate(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("preparing database %s: %s", config.Path, err)
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quote(c.Name)
+	}
+	d.insert = "INSERT OR IGNORE INTO logs (" + strings.Join(names, ", ") + ") VALUES (?" +
+		strings.Repeat(", ?", len(columns)-1) + ")"
+	return d, nil
+}
+
+// migrate sets up the connection and creates the tables, and adds the columns missing to the logs table
+// created by previous versions
+func (d *Destination) migrate() error {
+	var mode string
+	if err := d.db.QueryRow("PRAGMA journal_mode = WAL").Scan(&mode); err != nil {
+		return err
+	}
+	if mode != "wal" {
+		return fmt.Errorf("can not enable WAL mode, journal mode is %s", mode)
+	}
+	definitions := make([]string, len(columns))
+	for i, c := range columns {
+		definitions[i] = quote(c.Name) + " " + c.Type
+		if c.Name == "uuid" {
+			definitions[i] += " PRIMARY KEY"
+		}
+	}
+	statements := []string{
+		"PRAGMA synchronous = NORMAL", // durable enough in WAL mode: transactions are only lost on power failures
+		"PRAGMA busy_timeout = 5000",  // wait while other processes read the database
+		"CREATE TABLE IF NOT EXISTS logs (\n\t" + strings.Join(definitions, ",\n\t") + "\n)",
+	}
+	existing, err := d.existingColumns("logs")
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		if len(existing) > 0 && !existing[c.Name] { // tables created now have all the columns
+			statements = append(statements,
+				"ALTER TABLE logs ADD COLUMN "+quote(c.Name)+" "+strings.TrimSuffix(c.Type, " NOT NULL"))
+		}
+	}
+	statements = append(statements,
+		`CREATE INDEX IF NOT EXISTS logs_time ON logs ("time")`,
+		`CREATE INDEX IF NOT EXISTS logs_device_time ON logs ("device_udid", "time")`,
+		// full-text index of the text, kept up to date with triggers
+		`CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5("text", content = 'logs', content_rowid = 'rowid')`,
+		`CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+			INSERT INTO logs_fts (rowid, "text") VALUES (new.rowid, new."text");
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+			INSERT INTO logs_fts (logs_fts, rowid, "text") VALUES ('delete', old.rowid, old."text");
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS logs_fts_update AFTER UPDATE OF "text" ON logs BEGIN
+			INSERT INTO logs_fts (logs_fts, rowid, "text") VALUES ('delete', old.rowid, old."text");
+			INSERT INTO logs_fts (rowid, "text") VALUES (new.rowid, new."text");
+		END`,
+		`CREATE TABLE IF NOT EXISTS cursors (key TEXT PRIMARY KEY, api_host TEXT NOT NULL, cursor TEXT NOT NULL,
+			updated_at TEXT NOT NULL)`,
+	)
+	// cursors saved by previous versions have no API host, so they can't be resumed
+	if existing, err := d.existingColumns("cursors"); err != nil {
+		return err
+	} else if len(existing) > 0 && !existing["api_host"] {
+		statements = append(statements, `ALTER TABLE cursors ADD COLUMN api_host TEXT NOT NULL DEFAULT ''`)
+	}
+	for _, statement := range statements {
+		if _, err := d.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existingColumns returns the names of the columns of a table, none if it doesn't exist
+func (d *Destination) existingColumns(table string) (map[string]bool, error) {
+	rows, err := d.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	return existing, rows.Err()
+}
+
+// WriteLogs writes logs to the database, ignoring the logs already written
+func (d *Destination) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	return d.write(ctx, logs, "", integration.Cursor{})
+}
+
+// WriteLogsWithCursor writes logs to the database, ignoring the logs already written, and saves the cursor
+// in the same transaction
+func (d *Destination) WriteLogsWithCursor(ctx context.Context, logs []integration.Log, key string,
+	cursor integration.Cursor) error {
+	return d.write(ctx, logs, key, cursor)
+}
+
+// write inserts the logs and saves the cursor, if key is not empty, in a transaction
+func (d *Destination) write(ctx context.Context, logs []integration.Log, key string, cursor integration.Cursor) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }() // no-op once committed
+	stmt, err := tx.PrepareContext(ctx, d.insert)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+	inserted := int64(0)
+	values := make([]interface{}, len(columns))
+	for i := range logs {
+		for j, c := range columns {
+			values[j] = c.Value(&logs[i])
+		}
+		res, err := stmt.ExecContext(ctx, values...)
+		if err != nil {
+			return fmt.Errorf("inserting log %s: %s", logs[i].Uuid, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		inserted += n
+	}
+	if key != "" {
+		_, err = tx.ExecContext(ctx, "INSERT INTO cursors (key, api_host, cursor, updated_at)"+
+			" VALUES (?, ?, ?, strftime('%Y-%m-%dT%H:%M:%fZ'))"+
+			" ON CONFLICT (key) DO UPDATE SET api_host = excluded.api_host, cursor = excluded.cursor,"+
+			" updated_at = excluded.updated_at", key, cursor.APIHost, cursor.NextPage)
+		if err != nil {
+			return fmt.Errorf("saving cursor: %s", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if d.verbose && inserted < int64(len(logs)) {
+		log.Printf("Skipped %d logs already in the database", int64(len(logs))-inserted)
+	}
+	return nil
+}
+
+// Cursor returns the cursor saved with the given key, the zero Cursor if none
+func (d *Destination) Cursor(ctx context.Context, key string) (integration.Cursor, error) {
+	var cursor integration.Cursor
+	err := d.db.QueryRowContext(ctx, "SELECT api_host, cursor FROM cursors WHERE key = ?", key).
+		Scan(&cursor.APIHost, &cursor.NextPage)
+	if err == sql.ErrNoRows {
+		return integration.Cursor{}, nil
+	}
+	return cursor, err
+}
+
+// Close closes the database
+func (d *Destination) Close(_ context.Context) error {
+	return d.db.Close()
+}
+
+// quote quotes an identifier
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,200 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/netconn"
+)
+
+const (
+	// TransportUDP sends each log in a datagram (RFC 5426), without confirmation of delivery
+	TransportUDP = "udp"
+	// TransportTCP sends logs over TCP, with octet-counting framing (RFC 6587)
+	TransportTCP = "tcp"
+	// TransportTLS sends logs over TLS, with octet-counting framing (RFC 5425)
+	TransportTLS = "tls"
+)
+
+// DefaultSDID is the default ID of the structured data element, with the example enterprise number of RFC 5612
+const DefaultSDID = "bugfender@32473"
+
+// maxUDPBytes is the maximum size of the messages sent over UDP, longer messages are truncated
+const maxUDPBytes = 65000
+
+// Client forwards logs to a syslog server
+type Client struct {
+	address   string
+	transport string
+	tlsConfig *tls.Config
+	facility  int
+	appName   string
+	sdID      string
+	timeout   time.Duration
+	conn      *netconn.Conn
+}
+
+// Config contains the parameters to forward logs to syslog
+type Config struct {
+	// Address of the syslog server, eg. localhost:514
+	Address string
+	// Transport is TransportUDP, TransportTCP (default) or TransportTLS
+	Transport string
+	// TLS contains the TLS parameters, for TransportTLS
+	TLS TLSConfig
+	// Facility of the messages, from 0 to 23 (eg. 16 for local0)
+	Facility int
+	// AppName is the APP-NAME of the messages (default: bugfender)
+	AppName string
+	// SDID is the ID of the structured data element with the fields of the logs (default: DefaultSDID)
+	SDID string
+	// Timeout to connect and to send each batch of logs (default: 30s)
+	Timeout time.Duration
+}
+
+// TLSConfig contains the TLS parameters to connect to the server
+type TLSConfig struct {
+	// CAFile is a PEM file with the certificate authorities to trust (default: the system ones)
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key, for mutual TLS (default: none)
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify skips the verification of the server certificate (insecure)
+	InsecureSkipVerify bool
+}
+
+var _ integration.LogWriter = &Client{}
+
+// NewClient creates a syslog client with the given parameters. The connection is established when writing logs.
+// It is compulsory to call Close when done.
+func NewClient(config Config) (*Client, error) {
+	c := &Client{
+		address:   config.Address,
+		transport: config.Transport,
+		facility:  config.Facility,
+		appName:   config.AppName,
+		sdID:      config.SDID,
+		timeout:   config.Timeout,
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("a server address is needed")
+	}
+	switch c.transport {
+	case "":
+		c.transport = TransportTCP
+	case TransportUDP, TransportTCP:
+	case TransportTLS:
+		var err error
+		if c.tlsConfig, err = config.TLS.tlsConfig(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid transport %s, expected %s, %s or %s", c.transport,
+			TransportUDP, TransportTCP, TransportTLS)
+	}
+	if c.facility < 0 || c.facility > 23 {
+		return nil, fmt.Errorf("invalid facility %d, expected 0 to 23", c.facility)
+	}
+	if c.appName == "" {
+		c.appName = "bugfender"
+	}
+	if c.sdID == "" {
+		c.sdID = DefaultSDID
+	}
+	if !validSDID(c.sdID) {
+		return nil, fmt.Errorf("invalid structured data ID %s, expected name@<private enterprise number>", c.sdID)
+	}
+	if c.timeout == 0 {
+		c.timeout = 30 * time.Second
+	}
+	network := c.transport
+	if network == TransportTLS {
+		network = TransportTCP
+	}
+	c.conn = netconn.New(netconn.Config{
+		Name:    "syslog",
+		Network: network,
+		Address: c.address,
+		TLS:     c.tlsConfig,
+		Timeout: c.timeout,
+	})
+	return c, nil
+}
+
+func (config *TLSConfig) tlsConfig() (*tls.Config, error) {
+	// #nosec G402 InsecureSkipVerify is only set if the user asks for it
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// WriteLogs sends logs to the syslog server, connecting first if needed. If the connection is broken, it reconnects
+// and sends the logs again once.
+func (c *Client) WriteLogs(ctx context.Context, logs []integration.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	messages := make([][]byte, len(logs))
+	for i := range logs {
+		messages[i] = c.format(&logs[i])
+	}
+	err := c.conn.Write(ctx, func(w io.Writer) error {
+		return c.send(w, messages)
+	})
+	if err != nil {
+		return fmt.Errorf("sending logs to syslog: %s", err)
+	}
+	return nil
+}
+
+// send writes the messages
+func (c *Client) send(w io.Writer, messages [][]byte) error {
+	if c.transport == TransportUDP {
+		for _, m := range messages {
+			if len(m) > maxUDPBytes {
+				m = m[:maxUDPBytes]
+			}
+			if _, err := w.Write(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// octet-counting framing: MSG-LEN SP SYSLOG-MSG
+	var frames []byte
+	for _, m := range messages {
+		frames = append(frames, strconv.Itoa(len(m))...)
+		frames = append(frames, ' ')
+		frames = append(frames, m...)
+	}
+	_, err := w.Write(frames)
+	return err
+}
+
+// Close closes the connection
+func (c *Client) Close(_ context.Context) error {
+	return c.conn.Close()
+}
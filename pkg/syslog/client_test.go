@@ -0,0 +1,99 @@
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// writes records the data of each call to Write
+type writes [][]byte
+
+func (w *writes) Write(p []byte) (int, error) {
+	*w = append(*w, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestSend(t *testing.T) {
+	messages := [][]byte{[]byte("<14>1 a"), []byte("<14>1 ñ"), bytes.Repeat([]byte("x"), maxUDPBytes+1)}
+	for _, tt := range []struct {
+		transport string
+		want      [][]byte
+	}{
+		// octet counting: the length in bytes, a space and the message
+		{TransportTCP, [][]byte{[]byte("7 <14>1 a8 <14>1 ñ65001 " + string(messages[2]))}},
+		{TransportTLS, [][]byte{[]byte("7 <14>1 a8 <14>1 ñ65001 " + string(messages[2]))}},
+		// a datagram per message, truncated
+		{TransportUDP, [][]byte{messages[0], messages[1], messages[2][:maxUDPBytes]}},
+	} {
+		c := &Client{transport: tt.transport}
+		var w writes
+		if err := c.send(&w, messages); err != nil {
+			t.Fatal(err)
+		}
+		if len(w) != len(tt.want) {
+			t.Fatalf("%s: got %d writes, want %d", tt.transport, len(w), len(tt.want))
+		}
+		for i := range w {
+			if !bytes.Equal(w[i], tt.want[i]) {
+				t.Errorf("%s: write %d = %.40q, want %.40q", tt.transport, i, w[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestWriteLogsTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		received <- b
+	}()
+	c, err := NewClient(Config{Address: ln.Addr().String(), AppName: "app", Facility: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []integration.Log{{Level: integration.LevelInfo, Text: "first"}, {Level: integration.LevelFatal, Text: "second"}}
+	if err := c.WriteLogs(context.Background(), logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := `111 <134>1 - - app - - [bugfender@32473 uuid="00000000-0000-0000-0000-000000000000" app="0" log_level="info"] first` +
+		`113 <130>1 - - app - - [bugfender@32473 uuid="00000000-0000-0000-0000-000000000000" app="0" log_level="fatal"] second`
+	if got := string(<-received); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config Config
+		ok     bool
+	}{
+		{"defaults", Config{Address: "localhost:514"}, true},
+		{"no address", Config{}, false},
+		{"invalid transport", Config{Address: "localhost:514", Transport: "http"}, false},
+		{"invalid facility", Config{Address: "localhost:514", Facility: 24}, false},
+		{"invalid SD-ID", Config{Address: "localhost:514", SDID: "bugfender"}, false},
+	} {
+		if _, err := NewClient(tt.config); (err == nil) != tt.ok {
+			t.Errorf("%s: NewClient() error = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package syslog
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// timeFormat is the RFC 5424 timestamp format, with microseconds (the maximum precision allowed)
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// defaultSeverity is the severity of the logs with an unknown level (informational)
+const defaultSeverity = 6
+
+// sdParam is a parameter of the structured data element, taken from a log
+type sdParam struct {
+	name  string
+	value func(l *integration.Log) string
+}
+
+// sdParams are the parameters of the structured data element, named like the fields of the JSON documents
+var sdParams = []sdParam{
+	{"uuid", func(l *integration.Log) string { return l.Uuid.String() }},
+	{"app", func(l *integration.Log) string { return strconv.FormatInt(l.App, 10) }},
+	{"device.udid", func(l *integration.Log) string { return l.DeviceUDID }},
+	{"device.name", func(l *integration.Log) string { return l.DeviceName }},
+	{"device.type", func(l *integration.Log) string { return l.DeviceType }},
+	{"version.version", func(l *integration.Log) string { return l.VersionVersion }},
+	{"version.build", func(l *integration.Log) string { return l.VersionBuild }},
+	{"os_version", func(l *integration.Log) string { return l.OSVersion }},
+	{"log_level", func(l *integration.Log) string { return l.Level.String() }},
+	{"tag", func(l *integration.Log) string { return l.Tag }},
+	{"method", func(l *integration.Log) string { return l.Method }},
+	{"file", func(l *integration.Log) string { return l.File }},
+	{"line", func(l *integration.Log) string {
+		if l.Line == 0 {
+			return ""
+		}
+		return strconv.FormatInt(l.Line, 10)
+	}},
+}
+
+// format formats a log as an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID params] MSG
+// The hostname is the device UDID, the message ID is the tag and the message is the text of the log.
+// Empty parameters are left out of the structured data element.
+func (c *Client) format(l *integration.Log) []byte {
+	severity := l.Level.SyslogSeverity()
+	if severity < 0 {
+		severity = defaultSeverity
+	}
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(strconv.Itoa(c.facility*8 + severity))
+	b.WriteString(">1 ")
+	if l.Time.IsZero() {
+		b.WriteString("-")
+	} else {
+		b.WriteString(l.Time.Format(timeFormat))
+	}
+	b.WriteString(" ")
+	b.WriteString(headerField(l.DeviceUDID, 255))
+	b.WriteString(" ")
+	b.WriteString(headerField(c.appName, 48))
+	b.WriteString(" - ")
+	b.WriteString(headerField(l.Tag, 32))
+	b.WriteString(" [")
+	b.WriteString(c.sdID)
+	for _, p := range sdParams {
+		if v := p.value(l); v != "" {
+			b.WriteString(" ")
+			b.WriteString(p.name)
+			b.WriteString(`="`)
+			b.WriteString(sdEscaper.Replace(v))
+			b.WriteString(`"`)
+		}
+	}
+	b.WriteString("]")
+	if l.Text != "" {
+		b.WriteString(" ")
+		b.WriteString(l.Text)
+	}
+	return []byte(b.String())
+}
+
+// sdEscaper escapes the characters not allowed in parameter values
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// headerField returns a value valid as a header field: printable ASCII characters without spaces, up to max
+// characters, or "-" (nil value) if empty
+func headerField(s string, max int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < max; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			b = append(b, s[i])
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// validSDID returns whether the structured data ID is valid: up to 32 printable ASCII characters, except
+// '=', ' ', ']' and '"'. IDs not registered with IANA must contain '@' followed by a private enterprise number.
+func validSDID(id string) bool {
+	if id == "" || len(id) > 32 || !strings.Contains(id, "@") {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= 32 || id[i] >= 127 || id[i] == '=' || id[i] == ']' || id[i] == '"' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/gofrs/uuid"
+)
+
+func TestFormat(t *testing.T) {
+	c, err := NewClient(Config{Address: "localhost:514", Facility: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := integration.Log{
+		Uuid:           uuid.Must(uuid.FromString("6ba7b810-9dad-11d1-80b4-00c04fd430c8")),
+		App:            42,
+		DeviceUDID:     "udid-1",
+		DeviceName:     `John's "phone"`,
+		VersionVersion: "1.2",
+		Level:          integration.LevelError,
+		Tag:            "net",
+		Method:         `parse\json`,
+		File:           "a[1].go",
+		Line:           7,
+		Time:           time.Date(2021, 6, 1, 10, 20, 30, 123456789, time.UTC),
+		Text:           "request failed",
+	}
+	want := `<131>1 2021-06-01T10:20:30.123456Z udid-1 bugfender - net [bugfender@32473` +
+		` uuid="6ba7b810-9dad-11d1-80b4-00c04fd430c8" app="42" device.udid="udid-1" device.name="John's \"phone\""` +
+		` version.version="1.2" log_level="error" tag="net" method="parse\\json" file="a[1\].go" line="7"] request failed`
+	if got := string(c.format(&l)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// nil values
+	want = `<134>1 - - bugfender - - [bugfender@32473 uuid="00000000-0000-0000-0000-000000000000" app="0"` +
+		` log_level="level(9)"]`
+	if got := string(c.format(&integration.Log{Level: 9})); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatSeverity(t *testing.T) {
+	c, err := NewClient(Config{Address: "localhost:514", Facility: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		level integration.Level
+		pri   string
+	}{
+		{integration.LevelTrace, "<15>"},
+		{integration.LevelDebug, "<15>"},
+		{integration.LevelInfo, "<14>"},
+		{integration.LevelWarning, "<12>"},
+		{integration.LevelError, "<11>"},
+		{integration.LevelFatal, "<10>"},
+		{integration.Level(-1), "<14>"}, // unknown levels are informational
+	} {
+		got := string(c.format(&integration.Log{Level: tt.level}))
+		if got[:len(tt.pri)] != tt.pri {
+			t.Errorf("%s: got %s, want %s", tt.level, got[:len(tt.pri)], tt.pri)
+		}
+	}
+}
+
+func TestHeaderField(t *testing.T) {
+	for _, tt := range []struct {
+		s, want string
+	}{
+		{"", "-"},
+		{" \t", "-"},
+		{"a b\nc", "abc"},
+		{"ñandú", "and"},
+		{"abcdef", "abcd"},
+	} {
+		if got := headerField(tt.s, 4); got != tt.want {
+			t.Errorf("headerField(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestValidSDID(t *testing.T) {
+	for id, want := range map[string]bool{
+		DefaultSDID:                          true,
+		"bugfender":                          false,
+		"":                                   false,
+		"bug fender@32473":                   false,
+		"bug=fender@32473":                   false,
+		`bug"fender@32473`:                   false,
+		"bug]fender@32473":                   false,
+		"bugfender@324731234567890123456789": false,
+	} {
+		if got := validSDID(id); got != want {
+			t.Errorf("validSDID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package textparse
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// grokPatterns are the grok patterns that can be used as %{NAME} or %{NAME:field}
+var grokPatterns = map[string]string{
+	"INT":               `[+-]?\d+`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?`,
+	"WORD":              `\w+`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IP":                `(?:\d{1,3}\.){3}\d{1,3}|[A-Fa-f0-9:]*:[A-Fa-f0-9:.]+`,
+	"EMAILADDRESS":      `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+`,
+	"URI":               `[A-Za-z][A-Za-z0-9+.\-]*://\S+`,
+	"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`,
+}
+
+// numericGrokPatterns are the grok patterns whose captures are converted to numbers
+var numericGrokPatterns = map[string]bool{"INT": true, "NUMBER": true}
+
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::(\w+))?\}`)
+
+// compileGrok compiles a regular expression, which can contain grok pattern references
+func compileGrok(pattern string) (*regexp.Regexp, error) {
+	var err error
+	expr := grokReference.ReplaceAllStringFunc(pattern, func(ref string) string {
+		m := grokReference.FindStringSubmatch(ref)
+		name, field := m[1], m[2]
+		p, ok := grokPatterns[name]
+		if !ok {
+			err = fmt.Errorf("unknown grok pattern %q", name)
+			return ref
+		}
+		if field == "" {
+			return "(?:" + p + ")"
+		}
+		if numericGrokPatterns[name] {
+			field = numericPrefix + field
+		}
+		return "(?P<" + field + ">" + p + ")"
+	})
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(expr)
+}
+
+// numericPrefix marks the named captures whose values are converted to numbers
+const numericPrefix = "num__"
+
+// matchPattern returns the named captures of the first match of the regular expression in the text, or nil
+func matchPattern(re *regexp.Regexp, text string) map[string]interface{} {
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	values := make(map[string]interface{})
+	for i, name := range re.SubexpNames() {
+		if name == "" || i >= len(m) {
+			continue
+		}
+		if strings.HasPrefix(name, numericPrefix) {
+			values[strings.TrimPrefix(name, numericPrefix)] = typedValue(m[i])
+			continue
+		}
+		values[name] = m[i]
+	}
+	return values
+}
+
+// LoadPatterns reads the patterns to match by log tag from a file.
+// Each line contains a tag (or "*" for any tag) and a pattern, separated by whitespace.
+// Empty lines and lines starting with "#" are ignored.
+func LoadPatterns(path string) (map[string][]string, error) {
+	f, err := os.Open(path) // #nosec G304 user intends to load this file
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	patterns := make(map[string][]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: expected a tag and a pattern", path, n)
+		}
+		tag, pattern := line[:i], strings.TrimSpace(line[i:])
+		patterns[tag] = append(patterns[tag], pattern)
+	}
+	return patterns, scanner.Err()
+}
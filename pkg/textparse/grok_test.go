@@ -0,0 +1,72 @@
+package textparse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGrok(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		text    string
+		want    map[string]interface{}
+	}{
+		{`%{WORD:method} %{NOTSPACE:path} took %{NUMBER:ms}ms`, "GET /api/items took 12.5ms", map[string]interface{}{
+			"method": "GET", "path": "/api/items", "ms": 12.5,
+		}},
+		{`user %{EMAILADDRESS:email} from %{IPV4:ip} \(%{INT:attempts} attempts\)`,
+			"login: user jane@example.com from 10.1.2.3 (3 attempts)", map[string]interface{}{
+				"email": "jane@example.com", "ip": "10.1.2.3", "attempts": int64(3),
+			}},
+		{`^%{TIMESTAMP_ISO8601:ts} %{GREEDYDATA:rest}`, "2021-03-04T12:30:45.123Z something happened",
+			map[string]interface{}{"ts": "2021-03-04T12:30:45.123Z", "rest": "something happened"}},
+		{`id=%{UUID:id} %{QUOTEDSTRING:name}`, `id=8ac3ed4d-2c66-4d0c-a4e0-6e3e2b1f4c2a "John \"J\" Doe"`,
+			map[string]interface{}{"id": "8ac3ed4d-2c66-4d0c-a4e0-6e3e2b1f4c2a", "name": `"John \"J\" Doe"`}},
+		{`order (?P<order>\d+) %{WORD}`, "order 77 shipped", map[string]interface{}{"order": "77"}},
+		{`%{INT:n} items`, "no items", nil},
+	} {
+		re, err := compileGrok(tt.pattern)
+		if err != nil {
+			t.Fatalf("compileGrok(%q): %s", tt.pattern, err)
+		}
+		if got := matchPattern(re, tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pattern %q on %q = %#v, want %#v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+	if _, err := compileGrok(`%{NOPE:x}`); err == nil {
+		t.Error("compileGrok() with an unknown pattern succeeded")
+	}
+}
+
+func TestLoadPatterns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "textparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "patterns")
+	content := "# comment\n\nnetwork  %{WORD:method} %{NOTSPACE:path}\n*\tuser=%{INT:user}\nnetwork took %{NUMBER:ms}ms\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadPatterns(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"network": {"%{WORD:method} %{NOTSPACE:path}", "took %{NUMBER:ms}ms"},
+		"*":       {"user=%{INT:user}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadPatterns() = %q, want %q", got, want)
+	}
+	if err := ioutil.WriteFile(path, []byte("network\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPatterns(path); err == nil {
+		t.Error("LoadPatterns() with a line without pattern succeeded")
+	}
+}
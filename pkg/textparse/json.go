@@ -0,0 +1,58 @@
+package textparse
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxJSONAttempts is the maximum number of '{' positions where decoding a JSON object is attempted
+const maxJSONAttempts = 3
+
+// findJSONObject returns the fields of the first JSON object embedded in the text, or nil if none
+func findJSONObject(text string) map[string]interface{} {
+	offset := 0
+	for attempt := 0; attempt < maxJSONAttempts; attempt++ {
+		i := strings.IndexByte(text[offset:], '{')
+		if i < 0 {
+			return nil
+		}
+		offset += i
+		var object map[string]interface{}
+		d := json.NewDecoder(strings.NewReader(text[offset:]))
+		d.UseNumber()
+		if d.Decode(&object) == nil && len(object) > 0 {
+			return object
+		}
+		offset++
+	}
+	return nil
+}
+
+// limitDepth replaces the objects and arrays nested deeper than depth with their JSON encoding
+func limitDepth(v interface{}, depth int) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if depth <= 0 {
+			return encodeJSON(v)
+		}
+		for k, child := range v {
+			v[k] = limitDepth(child, depth-1)
+		}
+	case []interface{}:
+		if depth <= 0 {
+			return encodeJSON(v)
+		}
+		for i, child := range v {
+			v[i] = limitDepth(child, depth-1)
+		}
+	}
+	return v
+}
+
+func encodeJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err) // decoded values can always be encoded
+	}
+	return string(b)
+}
@@ -0,0 +1,95 @@
+package textparse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseLogfmt returns the key=value pairs found in the text, or nil if none.
+// Words that are not key=value pairs are ignored, so pairs can be mixed with free text.
+func parseLogfmt(text string) map[string]interface{} {
+	var values map[string]interface{}
+	for i := 0; i < len(text); {
+		// skip spaces
+		if text[i] == ' ' || text[i] == '\t' || text[i] == '\n' {
+			i++
+			continue
+		}
+		// key
+		start := i
+		for i < len(text) && isKeyChar(text[i]) {
+			i++
+		}
+		key := text[start:i]
+		if key == "" || i == len(text) || text[i] != '=' || !isKeyStart(key[0]) {
+			i = skipWord(text, i)
+			continue
+		}
+		i++ // '='
+		// value, quoted or bare
+		var value string
+		if i < len(text) && text[i] == '"' {
+			end := closingQuote(text, i)
+			if end < 0 {
+				return values // unterminated quote, ignore the rest
+			}
+			unquoted, err := strconv.Unquote(text[i : end+1])
+			if err != nil {
+				unquoted = text[i+1 : end]
+			}
+			value = unquoted
+			i = end + 1
+		} else {
+			start = i
+			i = skipWord(text, i)
+			value = text[start:i]
+		}
+		if values == nil {
+			values = make(map[string]interface{})
+		}
+		values[key] = typedValue(value)
+	}
+	return values
+}
+
+func isKeyStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isKeyChar(c byte) bool {
+	return isKeyStart(c) || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+func skipWord(text string, i int) int {
+	for i < len(text) && text[i] != ' ' && text[i] != '\t' && text[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+// closingQuote returns the position of the quote closing the one at position i, or -1
+func closingQuote(text string, i int) int {
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '"':
+			return j
+		}
+	}
+	return -1
+}
+
+// typedValue converts numbers and booleans, so that they can be aggregated
+func typedValue(s string) interface{} {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXnN") { // not hex, NaN or Inf
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return b
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package textparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want map[string]interface{}
+	}{
+		{`request done status=200 took=1.5 cached=true path=/api/v1`, map[string]interface{}{
+			"status": int64(200), "took": 1.5, "cached": true, "path": "/api/v1",
+		}},
+		{`msg="user logged in" user.id=42`, map[string]interface{}{"msg": "user logged in", "user.id": int64(42)}},
+		{`msg="say \"hi\"\tnow" next=1`, map[string]interface{}{"msg": "say \"hi\"\tnow", "next": int64(1)}},
+		{`msg="a\q" b=2`, map[string]interface{}{"msg": `a\q`, "b": int64(2)}}, // invalid escape, kept as it is
+		{`a=1 msg="unterminated b=2`, map[string]interface{}{"a": int64(1)}},
+		{`empty= quoted=""`, map[string]interface{}{"empty": "", "quoted": ""}},
+		{`hex=0x1f inf=Inf yes=True`, map[string]interface{}{"hex": "0x1f", "inf": "Inf", "yes": "True"}},
+		{`1a=x -b=y a==b url=http://x?y=z`, map[string]interface{}{"a": "=b", "url": "http://x?y=z"}},
+		{`no pairs here`, nil},
+	} {
+		if got := parseLogfmt(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLogfmt(%q) = %#v, want %#v", tt.text, got, tt.want)
+		}
+	}
+}
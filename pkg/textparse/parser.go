@@ -0,0 +1,154 @@
+package textparse
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// ConflictPolicy specifies which value is kept when the same field is extracted more than once
+type ConflictPolicy string
+
+const (
+	// KeepFirst keeps the value extracted first
+	KeepFirst ConflictPolicy = "first"
+	// KeepLast keeps the value extracted last
+	KeepLast ConflictPolicy = "last"
+)
+
+// Config configures a Parser
+type Config struct {
+	// JSON enables detection of JSON objects in the text
+	JSON bool
+	// Logfmt enables detection of key=value pairs in the text
+	Logfmt bool
+	// Patterns are the regular expressions (with named captures) or grok patterns to match, by log tag.
+	// Patterns under the "*" tag are matched against logs with any tag.
+	Patterns map[string][]string
+	// Field is the name of the field where the extracted values are written
+	Field string
+	// MaxDepth is the maximum nesting of the extracted objects, deeper values are written as JSON strings
+	MaxDepth int
+	// MaxSize is the maximum length of the texts to parse, longer texts are ignored
+	MaxSize int
+	// MaxFields is the maximum number of fields to extract from each text
+	MaxFields int
+	// Conflicts specifies which value is kept when the same field is extracted more than once
+	Conflicts ConflictPolicy
+}
+
+// DefaultConfig returns the default configuration, without any parser enabled
+func DefaultConfig() Config {
+	return Config{
+		Field:     "parsed",
+		MaxDepth:  5,
+		MaxSize:   16 * 1024,
+		MaxFields: 100,
+		Conflicts: KeepFirst,
+	}
+}
+
+// Parser extracts structured data embedded in the text of the logs
+type Parser struct {
+	config   Config
+	patterns map[string][]*regexp.Regexp
+}
+
+var _ integration.LogProcessor = &Parser{}
+
+// New creates a Parser with the given configuration
+func New(config Config) (*Parser, error) {
+	if config.Field == "" {
+		return nil, fmt.Errorf("a field name to write the extracted values is needed")
+	}
+	if err := integration.CheckExtraField(config.Field); err != nil {
+		return nil, fmt.Errorf("invalid field to write the extracted values: %s", err)
+	}
+	if config.Conflicts != KeepFirst && config.Conflicts != KeepLast {
+		return nil, fmt.Errorf("unknown conflict policy %q", config.Conflicts)
+	}
+	p := Parser{
+		config:   config,
+		patterns: make(map[string][]*regexp.Regexp),
+	}
+	for tag, patterns := range config.Patterns {
+		for _, pattern := range patterns {
+			re, err := compileGrok(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q for tag %q: %s", pattern, tag, err)
+			}
+			p.patterns[tag] = append(p.patterns[tag], re)
+		}
+	}
+	return &p, nil
+}
+
+// ProcessLogs adds the values extracted from the text to the logs, in place
+func (p *Parser) ProcessLogs(_ context.Context, logs []integration.Log) ([]integration.Log, error) {
+	for i := range logs {
+		l := &logs[i]
+		fields := p.Parse(l.Tag, l.Text)
+		if len(fields) == 0 {
+			continue
+		}
+		if l.Extra == nil {
+			l.Extra = make(map[string]interface{})
+		}
+		l.Extra[p.config.Field] = fields
+	}
+	return logs, nil
+}
+
+// Parse returns the values extracted from the text of a log with the given tag, or nil if none
+func (p *Parser) Parse(tag, text string) map[string]interface{} {
+	if text == "" || len(text) > p.config.MaxSize {
+		return nil
+	}
+	f := fieldSet{values: make(map[string]interface{}), config: &p.config}
+	for _, re := range p.patterns[tag] {
+		f.addAll(matchPattern(re, text))
+	}
+	if tag != "*" {
+		for _, re := range p.patterns["*"] {
+			f.addAll(matchPattern(re, text))
+		}
+	}
+	if p.config.JSON {
+		f.addAll(findJSONObject(text))
+	}
+	if p.config.Logfmt {
+		f.addAll(parseLogfmt(text))
+	}
+	if len(f.values) == 0 {
+		return nil
+	}
+	return f.values
+}
+
+// fieldSet accumulates the extracted fields, enforcing the limits in the configuration
+type fieldSet struct {
+	values map[string]interface{}
+	config *Config
+}
+
+func (f *fieldSet) addAll(values map[string]interface{}) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // so that the same fields are kept if there are too many
+	for _, k := range keys {
+		v := values[k]
+		if _, exists := f.values[k]; exists {
+			if f.config.Conflicts == KeepFirst {
+				continue
+			}
+		} else if len(f.values) >= f.config.MaxFields {
+			continue
+		}
+		f.values[k] = limitDepth(v, f.config.MaxDepth-1)
+	}
+}
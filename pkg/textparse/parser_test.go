@@ -0,0 +1,97 @@
+package textparse
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+func TestFindJSONObject(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want map[string]interface{}
+	}{
+		{`response: {"status": 200, "items": [1, 2]}`, map[string]interface{}{
+			"status": json.Number("200"), "items": []interface{}{json.Number("1"), json.Number("2")},
+		}},
+		{`{"a": 1} trailing text`, map[string]interface{}{"a": json.Number("1")}},
+		{`set {x} then {y} then {"found": true}`, map[string]interface{}{"found": true}},
+		{`{a} {b} {c} {"too": "late"}`, nil}, // only the first maxJSONAttempts braces are tried
+		{`empty {} object`, nil},
+		{`no object`, nil},
+	} {
+		if got := findJSONObject(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findJSONObject(%q) = %#v, want %#v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	config := DefaultConfig()
+	config.JSON = true
+	config.Logfmt = true
+	config.MaxDepth = 2
+	config.Patterns = map[string][]string{
+		"network": {`^%{WORD:method} `},
+		"*":       {`status=%{INT:code}`},
+	}
+	p, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.Parse("network", `GET status=200 {"method": "POST", "deep": {"a": {"b": 1}}}`)
+	want := map[string]interface{}{
+		"method": "GET", // the first value is kept
+		"code":   int64(200),
+		"status": int64(200),
+		"deep":   map[string]interface{}{"a": `{"b":1}`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+
+	config.Conflicts = KeepLast
+	config.MaxFields = 1
+	config.Patterns = nil
+	if p, err = New(config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Parse("", "b=1 a=2 b=3"), map[string]interface{}{"a": int64(2)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() with one field = %#v, want %#v", got, want)
+	}
+
+	config.MaxSize = 5
+	if p, err = New(config); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Parse("", "a=1 b=2"); got != nil {
+		t.Errorf("Parse() of a text longer than MaxSize = %#v, want nil", got)
+	}
+}
+
+func TestProcessLogs(t *testing.T) {
+	config := DefaultConfig()
+	config.Logfmt = true
+	p, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := p.ProcessLogs(context.Background(), []integration.Log{{Text: "user=42"}, {Text: "nothing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"user": int64(42)}; !reflect.DeepEqual(logs[0].Extra["parsed"], want) {
+		t.Errorf("extra = %#v, want parsed = %#v", logs[0].Extra, want)
+	}
+	if logs[1].Extra != nil {
+		t.Errorf("extra of a log without values = %#v, want nil", logs[1].Extra)
+	}
+
+	config.Field = "text"
+	if _, err := New(config); err == nil {
+		t.Error("New() with the name of a known field succeeded")
+	}
+}
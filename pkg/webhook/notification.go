@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+)
+
+// Notification is a notification sent by a Bugfender webhook
+type Notification struct {
+	// ID identifies the notification
+	ID string `json:"id"`
+	// Event is the kind of notification (eg. "issue.created")
+	Event string    `json:"event"`
+	App   int64     `json:"app"`
+	Time  time.Time `json:"time"`
+	// Issue is the issue the notification is about, if any
+	Issue *Issue `json:"issue,omitempty"`
+	// Logs are the logs included in the notification, if any
+	Logs []integration.Log `json:"logs,omitempty"`
+}
+
+// Issue is a Bugfender issue in a notification
+type Issue struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"`
+	Status   *int   `json:"status,omitempty"`
+}
+
+// webhookNamespace derives the log UUIDs from the notification IDs, so that duplicates are written only once
+var webhookNamespace = uuid.NewV5(uuid.NamespaceURL, "https://bugfender.com/webhook")
+
+// ToLogs converts the notification to logs.
+// The logs included in the notification are returned as they are. Otherwise, a log describing the event is returned.
+func (n *Notification) ToLogs() []integration.Log {
+	if len(n.Logs) > 0 {
+		return n.Logs
+	}
+	l := integration.Log{
+		Uuid:  uuid.NewV5(webhookNamespace, n.ID),
+		App:   n.App,
+		Time:  n.Time,
+		Type:  "webhook",
+		Text:  n.Event,
+		Level: integration.LevelInfo,
+		Extra: map[string]interface{}{"webhook.event": n.Event},
+	}
+	if l.Time.IsZero() {
+		l.Time = time.Now()
+	}
+	if n.Issue != nil {
+		issue := *n.Issue
+		l.Text = n.Event + ": " + issue.Title
+		l.IssueID = &issue.ID
+		l.IssueTitle = &issue.Title
+		l.IssueMarkdown = &issue.Markdown
+		l.IssueStatus = issue.Status
+	}
+	return []integration.Log{l}
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/signature"
+)
+
+// maxBodySize is the maximum size of a notification
+const maxBodySize = 1 << 20
+
+// Poller is implemented by integrations that can poll for new logs on request
+type Poller interface {
+	RequestPoll()
+}
+
+// Config configures a Handler
+type Config struct {
+	// Secret is the key shared with Bugfender to sign notifications
+	Secret []byte
+	// Tolerance is the maximum difference between the time a notification was sent and received
+	Tolerance time.Duration
+	// AppID is the app notifications are accepted for (0: any app)
+	AppID int64
+	// Poller, if not nil, is requested to poll for new logs when a notification is received
+	Poller  Poller
+	Verbose bool
+}
+
+// Handler receives notifications from Bugfender webhooks and writes them to a destination
+type Handler struct {
+	config      Config
+	destination integration.LogWriter
+	verifier    *verifier
+}
+
+// NewHandler creates a Handler that writes notifications to destination
+func NewHandler(config Config, destination integration.LogWriter) (*Handler, error) {
+	if len(config.Secret) == 0 {
+		return nil, errors.New("a secret to verify notifications is needed")
+	}
+	return &Handler{
+		config:      config,
+		destination: destination,
+		verifier:    newVerifier(config.Secret, config.Tolerance),
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, maxBodySize))
+	if err != nil {
+		http.Error(rw, "can not read body", http.StatusBadRequest)
+		return
+	}
+	sig := req.Header.Get(signature.Header)
+	err = h.verifier.verify(req.Header.Get(signature.TimestampHeader), sig, body, time.Now())
+	switch err {
+	case nil:
+	case errReplayed:
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	default:
+		log.Println("Rejected webhook notification:", err)
+		http.Error(rw, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var n Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		http.Error(rw, "invalid notification: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.config.AppID != 0 && n.App != h.config.AppID {
+		http.Error(rw, "unexpected app", http.StatusBadRequest)
+		return
+	}
+	logs := n.ToLogs()
+	if err := h.destination.WriteLogs(req.Context(), logs); err != nil {
+		log.Println("Error writing webhook notification:", err)
+		h.verifier.forget(sig) // accept it when Bugfender retries
+		http.Error(rw, "error writing notification", http.StatusInternalServerError)
+		return
+	}
+	if h.config.Verbose {
+		log.Printf("Wrote %d logs from webhook notification %s (%s)", len(logs), n.ID, n.Event)
+	}
+	if h.config.Poller != nil {
+		h.config.Poller.RequestPoll()
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// ListenAndServe serves the handler at the given address and path, until the context is cancelled
+func ListenAndServe(ctx context.Context, addr, path string, handler http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/integration"
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/signature"
+)
+
+var secret = []byte("secret")
+
+// destination records the logs written, or fails with err
+type destination struct {
+	logs []integration.Log
+	err  error
+}
+
+func (d *destination) WriteLogs(_ context.Context, logs []integration.Log) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.logs = append(d.logs, logs...)
+	return nil
+}
+
+// notify sends a notification to the handler with the given headers, and returns the status code
+func notify(h http.Handler, body string, headers map[string]string) int {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(body)))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	return rw.Code
+}
+
+// signed returns the headers of a body signed at the given time
+func signed(body string, sent time.Time) map[string]string {
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+	return map[string]string{
+		signature.TimestampHeader: timestamp,
+		signature.Header:          "sha256=" + signature.Sign(secret, timestamp, []byte(body)),
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	const body = `{"id":"n1","event":"issue.created","app":1,"issue":{"id":"i1","title":"crash"}}`
+	now := time.Now()
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"valid", signed(body, now), http.StatusNoContent},
+		{"valid without prefix", map[string]string{
+			signature.TimestampHeader: strconv.FormatInt(now.Unix(), 10),
+			signature.Header:          signature.Sign(secret, strconv.FormatInt(now.Unix(), 10), []byte(body)),
+		}, http.StatusNoContent},
+		{"wrong signature", map[string]string{
+			signature.TimestampHeader: strconv.FormatInt(now.Unix(), 10),
+			signature.Header:          "sha256=" + signature.Sign([]byte("other"), strconv.FormatInt(now.Unix(), 10), []byte(body)),
+		}, http.StatusUnauthorized},
+		{"signature of another body", signed(`{"id":"n2"}`, now), http.StatusUnauthorized},
+		{"missing signature", map[string]string{signature.TimestampHeader: strconv.FormatInt(now.Unix(), 10)},
+			http.StatusUnauthorized},
+		{"missing timestamp", map[string]string{signature.Header: signed(body, now)[signature.Header]},
+			http.StatusUnauthorized},
+		{"malformed timestamp", map[string]string{
+			signature.TimestampHeader: "yesterday",
+			signature.Header:          "sha256=" + signature.Sign(secret, "yesterday", []byte(body)),
+		}, http.StatusUnauthorized},
+		{"expired", signed(body, now.Add(-10*time.Minute)), http.StatusUnauthorized},
+		{"future", signed(body, now.Add(10*time.Minute)), http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &destination{}
+			h, err := NewHandler(Config{Secret: secret, Tolerance: 5 * time.Minute}, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := notify(h, body, test.headers); code != test.want {
+				t.Errorf("got status %d, want %d", code, test.want)
+			}
+			if written := len(d.logs) > 0; written != (test.want == http.StatusNoContent) {
+				t.Errorf("logs written: %v", d.logs)
+			}
+		})
+	}
+}
+
+func TestServeHTTPReplayed(t *testing.T) {
+	const body = `{"id":"n1","event":"issue.created","app":1}`
+	d := &destination{}
+	h, err := NewHandler(Config{Secret: secret, Tolerance: 5 * time.Minute}, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := signed(body, time.Now())
+	if code := notify(h, body, headers); code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := notify(h, body, headers); code != http.StatusConflict {
+		t.Errorf("replayed: got status %d, want %d", code, http.StatusConflict)
+	}
+	if len(d.logs) != 1 {
+		t.Errorf("%d logs written, want 1", len(d.logs))
+	}
+}
+
+func TestServeHTTPRetriedAfterWriteError(t *testing.T) {
+	const body = `{"id":"n1","event":"issue.created","app":1}`
+	d := &destination{err: errors.New("unavailable")}
+	h, err := NewHandler(Config{Secret: secret, Tolerance: 5 * time.Minute}, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := signed(body, time.Now())
+	if code := notify(h, body, headers); code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want %d", code, http.StatusInternalServerError)
+	}
+	// Bugfender sends it again, once the destination is back
+	d.err = nil
+	if code := notify(h, body, headers); code != http.StatusNoContent {
+		t.Errorf("retried: got status %d, want %d", code, http.StatusNoContent)
+	}
+	if len(d.logs) != 1 {
+		t.Errorf("%d logs written, want 1", len(d.logs))
+	}
+}
+
+func TestVerifierForgetsExpired(t *testing.T) {
+	v := newVerifier(secret, time.Minute)
+	sent := time.Unix(1000, 0)
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+	sig := signature.Sign(secret, timestamp, []byte("{}"))
+	if err := v.verify(timestamp, sig, []byte("{}"), sent); err != nil {
+		t.Fatal(err)
+	}
+	// other notifications received later evict it, it would be rejected as expired anyway
+	other := strconv.FormatInt(sent.Add(2*time.Minute).Unix(), 10)
+	if err := v.verify(other, signature.Sign(secret, other, []byte("{}")), []byte("{}"), sent.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.seen) != 1 {
+		t.Errorf("%d signatures remembered, want 1", len(v.seen))
+	}
+	if err := v.verify(timestamp, sig, []byte("{}"), sent.Add(2*time.Minute)); err != errExpired {
+		t.Errorf("got error %v, want %v", err, errExpired)
+	}
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bugfender/bugfender-integration-elasticsearch/pkg/signature"
+)
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errInvalidSignature = errors.New("invalid signature")
+	errExpired          = errors.New("timestamp out of tolerance")
+	errReplayed         = errors.New("notification already received")
+)
+
+// verifier checks the signature and timestamp of notifications, and rejects notifications received twice
+type verifier struct {
+	secret    []byte
+	tolerance time.Duration
+	mu        sync.Mutex
+	seen      map[string]time.Time // signatures received, with their timestamp
+}
+
+func newVerifier(secret []byte, tolerance time.Duration) *verifier {
+	return &verifier{
+		secret:    secret,
+		tolerance: tolerance,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+func (v *verifier) verify(timestamp, sig string, body []byte, now time.Time) error {
+	if timestamp == "" || sig == "" {
+		return errMissingSignature
+	}
+	sig = strings.TrimPrefix(sig, "sha256=")
+	expected := signature.Sign(v.secret, timestamp, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return errInvalidSignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidSignature
+	}
+	sent := time.Unix(seconds, 0)
+	if sent.Before(now.Add(-v.tolerance)) || sent.After(now.Add(v.tolerance)) {
+		return errExpired
+	}
+	// signatures are only remembered while their timestamp is within tolerance, older ones are rejected anyway
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for s, t := range v.seen {
+		if t.Before(now.Add(-v.tolerance)) {
+			delete(v.seen, s)
+		}
+	}
+	if _, ok := v.seen[expected]; ok {
+		return errReplayed
+	}
+	v.seen[expected] = sent
+	return nil
+}
+
+// forget forgets a notification received, so that it's accepted if it's sent again
+func (v *verifier) forget(sig string) {
+	v.mu.Lock()
+	delete(v.seen, strings.TrimPrefix(sig, "sha256="))
+	v.mu.Unlock()
+}